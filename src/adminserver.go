@@ -0,0 +1,52 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/apache/openserverless-streaming-proxy/config"
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+)
+
+func startAdminServer(admin config.AdminConfig, registry *streams.Registry, streamLimiter *limiter.Limiter, rec *recorder.Recorder) {
+	adminPort := strconv.Itoa(admin.Port)
+	router := http.NewServeMux()
+
+	router.HandleFunc("GET /admin/streams", handlers.ListStreamsHandler(registry))
+	router.HandleFunc("GET /admin/streams/{id}", handlers.GetStreamHandler(registry))
+	router.HandleFunc("DELETE /admin/streams/{id}", handlers.TerminateStreamHandler(registry))
+	router.HandleFunc("GET /admin/limits", handlers.LimitsUsageHandler(streamLimiter))
+	router.HandleFunc("GET /admin/sockets", handlers.SocketMetricsHandler())
+	router.HandleFunc("GET /admin/replay/{id}", handlers.ReplayHandler(rec))
+
+	server := &http.Server{
+		Addr:    ":" + adminPort,
+		Handler: handlers.AdminAuth(admin.Token, router),
+	}
+
+	log.Println("Admin server listening on port", adminPort)
+	if err := server.ListenAndServe(); err != nil {
+		log.Println("Error starting admin server:", err)
+	}
+}
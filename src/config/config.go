@@ -0,0 +1,807 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"gopkg.in/yaml.v3"
+)
+
+type Config struct {
+	APIHost      string         `yaml:"apihost"`
+	StreamerAddr string         `yaml:"streamer_addr"`
+	HTTP         HTTPConfig     `yaml:"http"`
+	Admin        AdminConfig    `yaml:"admin"`
+	GRPC         GRPCConfig     `yaml:"grpc"`
+	Backend      BackendConfig  `yaml:"backend"`
+	Auth         AuthConfig     `yaml:"auth"`
+	Dev          DevConfig      `yaml:"dev"`
+	Stream       StreamConfig   `yaml:"stream"`
+	CORS         CORSConfig     `yaml:"cors"`
+	Limits       LimitsConfig   `yaml:"limits"`
+	Timeouts     TimeoutsConfig `yaml:"timeouts"`
+	Routes       RoutesConfig   `yaml:"routes"`
+	Params       ParamsConfig   `yaml:"params"`
+	Record       RecordConfig   `yaml:"record"`
+}
+
+type HTTPConfig struct {
+	Port int       `yaml:"port"`
+	TLS  TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig enables HTTPS when CertFile and KeyFile are set. ClientAuth is
+// none, request (verify the client certificates sent) or require.
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	ClientAuth   string `yaml:"client_auth"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+type AdminConfig struct {
+	// Port of the admin listener, 0 disables it.
+	Port  int    `yaml:"port"`
+	Token string `yaml:"token"`
+}
+
+// GRPCConfig serves the gRPC API, with the TLS settings of http.
+type GRPCConfig struct {
+	// Port of the gRPC listener, 0 disables it.
+	Port int `yaml:"port"`
+}
+
+// BackendConfig selects what runs the actions: openwhisk, on apihost, a
+// webhook, or a local process.
+type BackendConfig struct {
+	Type    string        `yaml:"type"`
+	Webhook WebhookConfig `yaml:"webhook"`
+	Process ProcessConfig `yaml:"process"`
+}
+
+// WebhookConfig posts the parameters of the actions to URL, where
+// {namespace} and {action} are replaced by those of the action.
+type WebhookConfig struct {
+	URL string `yaml:"url"`
+}
+
+// ProcessConfig runs Command, split on spaces, in Dir for every action.
+type ProcessConfig struct {
+	Command string `yaml:"command"`
+	Dir     string `yaml:"dir"`
+}
+
+// AuthConfig sets how the callers authenticate. In passthrough mode they
+// send the OpenWhisk key; in vault mode they send a key of CallersFile, and
+// in jwt mode a token, and the OpenWhisk key of the namespace is taken from
+// the vault. Without an Authorization header, the credential is read from
+// the Query parameter or the Cookie cookie, when set.
+type AuthConfig struct {
+	Mode        string      `yaml:"mode"`
+	Query       string      `yaml:"query"`
+	Cookie      string      `yaml:"cookie"`
+	CallersFile string      `yaml:"callers_file"`
+	Vault       VaultConfig `yaml:"vault"`
+	JWT         JWTConfig   `yaml:"jwt"`
+}
+
+// JWTConfig verifies the tokens with the keys of JWKSFile or, without one,
+// of Issuer, fetched again after KeysTTL. Rules maps claims to the value
+// they must have or include, {ns} being the namespace, and ForwardClaims
+// are given to the actions.
+type JWTConfig struct {
+	JWKSFile      string            `yaml:"jwks_file"`
+	Issuer        string            `yaml:"issuer"`
+	Audience      string            `yaml:"audience"`
+	KeysTTL       Duration          `yaml:"keys_ttl"`
+	Leeway        Duration          `yaml:"leeway"`
+	Rules         map[string]string `yaml:"rules"`
+	ForwardClaims []string          `yaml:"forward_claims"`
+}
+
+// VaultConfig reads the OpenWhisk keys from File, mapping the namespaces to
+// their keys, and from Dir, holding a file per namespace.
+type VaultConfig struct {
+	File string `yaml:"file"`
+	Dir  string `yaml:"dir"`
+}
+
+// DevConfig runs an OpenWhisk emulator on Port, for local development. Its
+// Actions are commands, by action name, run with the parameters as JSON on
+// their standard input.
+type DevConfig struct {
+	Enabled bool              `yaml:"enabled"`
+	Port    int               `yaml:"port"`
+	Actions map[string]string `yaml:"actions"`
+}
+
+// StreamConfig configures the sockets the actions stream to.
+type StreamConfig struct {
+	// BindAddr is the address the sockets listen on, streamer_addr when empty.
+	BindAddr string `yaml:"bind_addr"`
+	// AdvertiseHost is the host given to the actions, the address the socket
+	// is bound to when empty.
+	AdvertiseHost string `yaml:"advertise_host"`
+	// PortRange restricts the ports of the sockets, as "min-max".
+	PortRange string `yaml:"port_range"`
+	// IngestBaseURL is the URL the actions reach the streamer at, to post
+	// their output over HTTP. The URL of the client request when empty.
+	IngestBaseURL string `yaml:"ingest_base_url"`
+	// SocketDir, when set, makes the actions stream to Unix sockets in this
+	// directory instead of TCP ports.
+	SocketDir string `yaml:"socket_dir"`
+	// SocketMode is the permissions of the Unix sockets, in octal.
+	SocketMode string `yaml:"socket_mode"`
+	// SocketGroup, when set, is the group of the Unix sockets, a name or a
+	// gid, for the actions running as another user.
+	SocketGroup string          `yaml:"socket_group"`
+	Heartbeat   HeartbeatConfig `yaml:"heartbeat"`
+	// IdleTimeout ends the streams whose action writes nothing for that long.
+	IdleTimeout Duration `yaml:"idle_timeout"`
+	// TCPKeepAlive is the period of the keepalive probes on the action
+	// connections, 0 disables them.
+	TCPKeepAlive Duration        `yaml:"tcp_keepalive"`
+	TLS          StreamTLSConfig `yaml:"tls"`
+}
+
+// HeartbeatConfig writes a keepalive to the clients after Interval without
+// output from the action. Format is newline (an empty line) or sse (an SSE
+// comment).
+type HeartbeatConfig struct {
+	Interval Duration `yaml:"interval"`
+	Format   string   `yaml:"format"`
+}
+
+func (h HeartbeatConfig) Message() string {
+	if h.Format == "sse" {
+		return ": keepalive\n\n"
+	}
+	return "\n"
+}
+
+// StreamTLSConfig makes the actions connect with TLS when both files are set.
+type StreamTLSConfig struct {
+	CertFile string `yaml:"cert_file"`
+	KeyFile  string `yaml:"key_file"`
+}
+
+func (t StreamTLSConfig) Enabled() bool {
+	return t.CertFile != ""
+}
+
+// CORSConfig is the CORS policy of the public endpoints. AllowOrigin lists
+// the allowed origins, separated by commas, as https://app.example.com,
+// https://*.example.com for the subdomains, or * for any origin. Routes
+// override the policy for some paths.
+type CORSConfig struct {
+	Enabled          bool        `yaml:"enabled"`
+	AllowOrigin      string      `yaml:"allow_origin"`
+	AllowMethods     string      `yaml:"allow_methods"`
+	AllowHeaders     string      `yaml:"allow_headers"`
+	AllowCredentials bool        `yaml:"allow_credentials"`
+	ExposeHeaders    string      `yaml:"expose_headers"`
+	MaxAge           Duration    `yaml:"max_age"`
+	Routes           []CORSRoute `yaml:"routes"`
+}
+
+// CORSRoute overrides the CORS policy for the paths starting with Path. The
+// settings it does not set are the ones of the policy.
+type CORSRoute struct {
+	Path             string    `yaml:"path"`
+	AllowOrigin      string    `yaml:"allow_origin"`
+	AllowMethods     string    `yaml:"allow_methods"`
+	AllowHeaders     string    `yaml:"allow_headers"`
+	AllowCredentials *bool     `yaml:"allow_credentials"`
+	ExposeHeaders    string    `yaml:"expose_headers"`
+	MaxAge           *Duration `yaml:"max_age"`
+}
+
+// Origins splits AllowOrigin.
+func (c CORSConfig) Origins() []string {
+	return strings.FieldsFunc(c.AllowOrigin, func(r rune) bool { return r == ',' || r == ' ' })
+}
+
+// Override returns the policy of route, without routes.
+func (c CORSConfig) Override(route CORSRoute) CORSConfig {
+	c.Routes = nil
+	if route.AllowOrigin != "" {
+		c.AllowOrigin = route.AllowOrigin
+	}
+	if route.AllowMethods != "" {
+		c.AllowMethods = route.AllowMethods
+	}
+	if route.AllowHeaders != "" {
+		c.AllowHeaders = route.AllowHeaders
+	}
+	if route.AllowCredentials != nil {
+		c.AllowCredentials = *route.AllowCredentials
+	}
+	if route.ExposeHeaders != "" {
+		c.ExposeHeaders = route.ExposeHeaders
+	}
+	if route.MaxAge != nil {
+		c.MaxAge = *route.MaxAge
+	}
+	return c
+}
+
+type Limit struct {
+	Rate    float64 `yaml:"rate"`
+	Burst   int     `yaml:"burst"`
+	Streams int     `yaml:"streams"`
+}
+
+type LimitsConfig struct {
+	Global    Limit `yaml:"global"`
+	Namespace Limit `yaml:"namespace"`
+	APIKey    Limit `yaml:"apikey"`
+}
+
+type TimeoutsConfig struct {
+	ReadHeader Duration `yaml:"read_header"`
+	DrainDelay Duration `yaml:"drain_delay"`
+	Shutdown   Duration `yaml:"shutdown"`
+}
+
+type RoutesConfig struct {
+	Action bool          `yaml:"action"`
+	Web    bool          `yaml:"web"`
+	Table  []RouteConfig `yaml:"table"`
+}
+
+// ParamsConfig is how the parameters sent by the callers are read.
+// MaxBodySize limits the JSON bodies, in bytes, 0 for no limit. Reserved
+// is what to do with the parameters named as the STREAM_ ones the streamer
+// adds: reject them, or namespace them as CLIENT_STREAM_.
+type ParamsConfig struct {
+	MaxBodySize int    `yaml:"max_body_size"`
+	Reserved    string `yaml:"reserved"`
+}
+
+// RecordConfig records the streams to Dir, to replay them, keeping at most
+// MaxStreams recordings, MaxSize bytes in total, for MaxAge. 0 means no
+// limit.
+type RecordConfig struct {
+	Dir        string   `yaml:"dir"`
+	MaxStreams int      `yaml:"max_streams"`
+	MaxSize    int      `yaml:"max_size"`
+	MaxAge     Duration `yaml:"max_age"`
+}
+
+func (r RecordConfig) Enabled() bool {
+	return r.Dir != ""
+}
+
+func (r RecordConfig) Options() recorder.Options {
+	return recorder.Options{
+		Dir:        r.Dir,
+		MaxStreams: r.MaxStreams,
+		MaxSize:    int64(r.MaxSize),
+		MaxAge:     r.MaxAge.Duration(),
+	}
+}
+
+// RouteConfig serves an action on a path of its own, with its defaults.
+type RouteConfig struct {
+	Path      string                 `yaml:"path"`
+	Namespace string                 `yaml:"namespace"`
+	Action    string                 `yaml:"action"`
+	Type      string                 `yaml:"type"`
+	Methods   []string               `yaml:"methods"`
+	Format    string                 `yaml:"format"`
+	Params    map[string]interface{} `yaml:"params"`
+	// Schema is a JSON Schema file the parameters are validated against.
+	Schema string `yaml:"schema"`
+}
+
+// AllowedMethods are the methods the route answers to, GET and POST by
+// default.
+func (r RouteConfig) AllowedMethods() []string {
+	if len(r.Methods) == 0 {
+		return []string{"GET", "POST"}
+	}
+	methods := make([]string, len(r.Methods))
+	for i, method := range r.Methods {
+		methods[i] = strings.ToUpper(method)
+	}
+	return methods
+}
+
+// Default returns the configuration used for anything that is not set.
+func Default() *Config {
+	return &Config{
+		HTTP: HTTPConfig{
+			Port: 80,
+			TLS:  TLSConfig{ClientAuth: "none"},
+		},
+		CORS: CORSConfig{
+			AllowOrigin:   "*",
+			AllowMethods:  "GET, POST, OPTIONS",
+			AllowHeaders:  "*",
+			ExposeHeaders: "X-Stream-Status, X-Stream-Bytes, X-Activation-Id, X-Stream-Id, Location, Retry-After",
+		},
+		Stream: StreamConfig{
+			SocketMode:   "0660",
+			Heartbeat:    HeartbeatConfig{Format: "newline"},
+			TCPKeepAlive: Duration(15 * time.Second),
+		},
+		Timeouts: TimeoutsConfig{
+			ReadHeader: Duration(10 * time.Second),
+			Shutdown:   Duration(30 * time.Second),
+		},
+		Routes:  RoutesConfig{Action: true, Web: true},
+		Params:  ParamsConfig{MaxBodySize: 1 << 20, Reserved: "reject"},
+		Record:  RecordConfig{MaxStreams: 1000, MaxSize: 256 << 20, MaxAge: Duration(7 * 24 * time.Hour)},
+		Backend: BackendConfig{Type: "openwhisk"},
+		Dev:     DevConfig{Port: 3233},
+		Auth: AuthConfig{
+			Mode: "passthrough",
+			JWT:  JWTConfig{KeysTTL: Duration(time.Hour), Leeway: Duration(30 * time.Second)},
+		},
+	}
+}
+
+// applyDev points the streamer to the OpenWhisk emulator, in dev mode.
+func (c *Config) applyDev() {
+	if !c.Dev.Enabled {
+		return
+	}
+	c.APIHost = "http://127.0.0.1:" + strconv.Itoa(c.Dev.Port)
+	if c.StreamerAddr == "" {
+		c.StreamerAddr = "127.0.0.1"
+	}
+	// the local actions reach the streamer on the loopback interface
+	if c.Stream.IngestBaseURL == "" {
+		scheme := "http"
+		if c.HTTP.TLS.Enabled() {
+			scheme = "https"
+		}
+		c.Stream.IngestBaseURL = scheme + "://127.0.0.1:" + strconv.Itoa(c.HTTP.Port)
+	}
+}
+
+// Validate checks the whole configuration, reporting all the problems at once.
+func (c *Config) Validate() error {
+	var errs []error
+	if c.APIHost == "" {
+		if c.Backend.Type == "openwhisk" {
+			errs = append(errs, errors.New("apihost is required (OW_APIHOST, --apihost)"))
+		}
+	} else if err := validateAPIHost(c.APIHost); err != nil {
+		errs = append(errs, fmt.Errorf("apihost %q is not valid: %w", c.APIHost, err))
+	}
+	switch c.Backend.Type {
+	case "openwhisk":
+	case "webhook":
+		if u, err := url.Parse(c.Backend.Webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("backend.webhook.url %q is not an http or https URL (BACKEND_WEBHOOK_URL)", c.Backend.Webhook.URL))
+		}
+	case "process":
+		if strings.TrimSpace(c.Backend.Process.Command) == "" {
+			errs = append(errs, errors.New("backend.process.command is required (BACKEND_PROCESS_COMMAND)"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("backend.type %q is not one of openwhisk, webhook, process", c.Backend.Type))
+	}
+	switch c.Auth.Mode {
+	case "passthrough":
+	case "vault", "jwt":
+		if c.Auth.Mode == "vault" && c.Auth.CallersFile == "" {
+			errs = append(errs, errors.New("auth.callers_file is required in vault mode (AUTH_CALLERS_FILE)"))
+		}
+		if c.Auth.Mode == "jwt" {
+			errs = append(errs, c.Auth.JWT.validate()...)
+		}
+		if c.Auth.Vault.File == "" && c.Auth.Vault.Dir == "" {
+			errs = append(errs, fmt.Errorf("auth.vault: file or dir is required in %s mode (AUTH_VAULT_FILE, AUTH_VAULT_DIR)", c.Auth.Mode))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("auth.mode %q is not one of passthrough, vault, jwt", c.Auth.Mode))
+	}
+	// the actions connect to the Unix sockets without it
+	if c.StreamerAddr == "" && c.Stream.SocketDir == "" {
+		errs = append(errs, errors.New("streamer_addr is required without stream.socket_dir (STREAMER_ADDR, --streamer-addr)"))
+	}
+	if err := validatePort(c.HTTP.Port); err != nil {
+		errs = append(errs, fmt.Errorf("http.port: %w", err))
+	}
+	errs = append(errs, c.HTTP.TLS.validate("http.tls")...)
+	if c.StreamerAddr != "" && c.Stream.AdvertiseHost == "" {
+		if ip := net.ParseIP(c.BindAddr()); ip != nil && ip.IsUnspecified() {
+			errs = append(errs, fmt.Errorf("stream.advertise_host is required when binding to %s (STREAM_ADVERTISE_HOST)", c.BindAddr()))
+		}
+	}
+	if _, err := parsePortRange(c.Stream.PortRange); err != nil {
+		errs = append(errs, fmt.Errorf("stream.port_range: %w", err))
+	}
+	if _, err := parseSocketMode(c.Stream.SocketMode); err != nil {
+		errs = append(errs, fmt.Errorf("stream.socket_mode: %w", err))
+	}
+	if c.Stream.IngestBaseURL != "" {
+		if u, err := url.Parse(c.Stream.IngestBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("stream.ingest_base_url %q is not an http or https URL", c.Stream.IngestBaseURL))
+		}
+	}
+	if c.Stream.Heartbeat.Format != "newline" && c.Stream.Heartbeat.Format != "sse" {
+		errs = append(errs, fmt.Errorf("stream.heartbeat.format %q is not one of newline, sse", c.Stream.Heartbeat.Format))
+	}
+	streamDurations := []Duration{c.Stream.Heartbeat.Interval, c.Stream.IdleTimeout, c.Stream.TCPKeepAlive}
+	for i, name := range []string{"heartbeat.interval", "idle_timeout", "tcp_keepalive"} {
+		if streamDurations[i] < 0 {
+			errs = append(errs, fmt.Errorf("stream.%s must not be negative", name))
+		}
+	}
+	if (c.Stream.TLS.CertFile == "") != (c.Stream.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("stream.tls: cert_file and key_file must be set together"))
+	}
+	if c.Admin.Port != 0 {
+		if err := validatePort(c.Admin.Port); err != nil {
+			errs = append(errs, fmt.Errorf("admin.port: %w", err))
+		} else if c.Admin.Port == c.HTTP.Port {
+			errs = append(errs, errors.New("admin.port must differ from http.port"))
+		}
+		if c.Admin.Token == "" {
+			errs = append(errs, errors.New("admin.token is required when the admin listener is enabled (ADMIN_TOKEN)"))
+		}
+	}
+	if c.GRPC.Port != 0 {
+		if err := validatePort(c.GRPC.Port); err != nil {
+			errs = append(errs, fmt.Errorf("grpc.port: %w", err))
+		} else if c.GRPC.Port == c.HTTP.Port || c.GRPC.Port == c.Admin.Port {
+			errs = append(errs, errors.New("grpc.port must differ from http.port and admin.port"))
+		}
+	}
+	if c.Dev.Enabled {
+		if err := validatePort(c.Dev.Port); err != nil {
+			errs = append(errs, fmt.Errorf("dev.port: %w", err))
+		} else if c.Dev.Port == c.HTTP.Port {
+			errs = append(errs, errors.New("dev.port must differ from http.port"))
+		}
+	}
+	if c.CORS.Enabled {
+		errs = append(errs, c.CORS.validate("cors")...)
+		for i, route := range c.CORS.Routes {
+			key := fmt.Sprintf("cors.routes[%d]", i)
+			if !strings.HasPrefix(route.Path, "/") {
+				errs = append(errs, fmt.Errorf("%s: path %q must start with /", key, route.Path))
+			}
+			errs = append(errs, c.CORS.Override(route).validate(key)...)
+		}
+	}
+	limits := []Limit{c.Limits.Global, c.Limits.Namespace, c.Limits.APIKey}
+	for i, name := range []string{"global", "namespace", "apikey"} {
+		if limits[i].Rate < 0 || limits[i].Burst < 0 || limits[i].Streams < 0 {
+			errs = append(errs, fmt.Errorf("limits.%s: values must not be negative", name))
+		}
+	}
+	timeouts := []Duration{c.Timeouts.ReadHeader, c.Timeouts.DrainDelay, c.Timeouts.Shutdown}
+	for i, name := range []string{"read_header", "drain_delay", "shutdown"} {
+		if timeouts[i] < 0 {
+			errs = append(errs, fmt.Errorf("timeouts.%s must not be negative", name))
+		}
+	}
+	if !c.Routes.Action && !c.Routes.Web && len(c.Routes.Table) == 0 {
+		errs = append(errs, errors.New("routes: at least one of action and web must be enabled, or a table given"))
+	}
+	if c.Params.MaxBodySize < 0 {
+		errs = append(errs, errors.New("params.max_body_size must not be negative"))
+	}
+	if c.Params.Reserved != "reject" && c.Params.Reserved != "namespace" {
+		errs = append(errs, fmt.Errorf("params.reserved %q is not one of reject, namespace", c.Params.Reserved))
+	}
+	if c.Record.MaxStreams < 0 || c.Record.MaxSize < 0 || c.Record.MaxAge < 0 {
+		errs = append(errs, errors.New("record: max_streams, max_size and max_age must not be negative"))
+	}
+	paths := make(map[string]bool)
+	for i, route := range c.Routes.Table {
+		errs = append(errs, route.validate(fmt.Sprintf("routes.table[%d]", i))...)
+		if paths[route.Path] {
+			errs = append(errs, fmt.Errorf("routes.table[%d]: path %q is already routed", i, route.Path))
+		}
+		paths[route.Path] = true
+	}
+	return errors.Join(errs...)
+}
+
+// reservedPaths are the endpoints of the streamer, and the prefixes of those
+// with wildcards, which the route table can not use.
+var (
+	reservedPaths    = []string{"/healthz", "/readyz"}
+	reservedPrefixes = []string{"/action/", "/web/", "/ingest/", "/poll/"}
+)
+
+func reservedPath(path string) bool {
+	for _, reserved := range reservedPaths {
+		if path == reserved || path == reserved+"/" {
+			return true
+		}
+	}
+	for _, prefix := range reservedPrefixes {
+		if strings.HasPrefix(path+"/", prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RouteConfig) validate(key string) []error {
+	var errs []error
+	if !strings.HasPrefix(r.Path, "/") || r.Path == "/" || strings.ContainsAny(r.Path, "{} ") {
+		errs = append(errs, fmt.Errorf("%s: path %q must start with / and have no wildcards", key, r.Path))
+	} else if reservedPath(r.Path) {
+		errs = append(errs, fmt.Errorf("%s: path %q is served by the streamer", key, r.Path))
+	}
+	if r.Namespace == "" || r.Action == "" {
+		errs = append(errs, fmt.Errorf("%s: namespace and action are required", key))
+	}
+	if r.Type != "action" && r.Type != "web" {
+		errs = append(errs, fmt.Errorf("%s: type %q is not one of action, web", key, r.Type))
+	}
+	for _, method := range r.AllowedMethods() {
+		if method != "GET" && method != "POST" {
+			errs = append(errs, fmt.Errorf("%s: method %q is not one of GET, POST", key, method))
+		}
+	}
+	switch r.Format {
+	case "", "text", "ndjson":
+	default:
+		errs = append(errs, fmt.Errorf("%s: format %q is not one of text, ndjson", key, r.Format))
+	}
+	for name := range r.Params {
+		if strings.HasPrefix(name, "STREAM_") {
+			errs = append(errs, fmt.Errorf("%s: param %q is reserved to the streamer", key, name))
+		}
+	}
+	return errs
+}
+
+func (c CORSConfig) validate(key string) []error {
+	var errs []error
+	origins := c.Origins()
+	if len(origins) == 0 {
+		errs = append(errs, fmt.Errorf("%s: allow_origin is required when CORS is enabled", key))
+	}
+	for _, origin := range origins {
+		if origin == "*" {
+			if c.AllowCredentials {
+				errs = append(errs, fmt.Errorf("%s: allow_credentials requires the origins to be listed, not *", key))
+			}
+			continue
+		}
+		u, err := url.Parse(strings.Replace(origin, "://*.", "://", 1))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || (u.Path != "" && u.Path != "/") || strings.Contains(u.Host, "*") {
+			errs = append(errs, fmt.Errorf("%s: origin %q is not an origin, as https://app.example.com or https://*.example.com", key, origin))
+		}
+	}
+	if c.MaxAge < 0 {
+		errs = append(errs, fmt.Errorf("%s: max_age must not be negative", key))
+	}
+	return errs
+}
+
+func (j JWTConfig) validate() []error {
+	var errs []error
+	if j.JWKSFile == "" && j.Issuer == "" {
+		errs = append(errs, errors.New("auth.jwt: jwks_file or issuer is required in jwt mode (AUTH_JWT_JWKS_FILE, AUTH_JWT_ISSUER)"))
+	}
+	if j.Issuer != "" {
+		if u, err := url.Parse(j.Issuer); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("auth.jwt.issuer %q is not an http or https URL", j.Issuer))
+		}
+	}
+	if j.KeysTTL <= 0 || j.Leeway < 0 {
+		errs = append(errs, errors.New("auth.jwt: keys_ttl must be positive and leeway not negative"))
+	}
+	// the key of the namespace of the URL is given to the callers, so the
+	// token must be bound to it
+	bound := false
+	for _, value := range j.Rules {
+		bound = bound || strings.Contains(value, "{ns}")
+	}
+	if !bound {
+		errs = append(errs, errors.New("auth.jwt.rules: a rule with {ns} is required, binding the tokens to the namespaces whose keys they get"))
+	}
+	return errs
+}
+
+func (t TLSConfig) validate(key string) []error {
+	var errs []error
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		errs = append(errs, fmt.Errorf("%s: cert_file and key_file must be set together", key))
+	}
+	switch t.ClientAuth {
+	case "", "none":
+	case "request", "require":
+		if !t.Enabled() {
+			errs = append(errs, fmt.Errorf("%s: client_auth requires cert_file and key_file", key))
+		}
+		if t.ClientCAFile == "" {
+			errs = append(errs, fmt.Errorf("%s: client_auth %s requires client_ca_file", key, t.ClientAuth))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("%s: client_auth %q is not one of none, request, require", key, t.ClientAuth))
+	}
+	return errs
+}
+
+func validateAPIHost(apihost string) error {
+	if !strings.HasPrefix(apihost, "http://") && !strings.HasPrefix(apihost, "https://") {
+		apihost = "https://" + apihost
+	}
+	u, err := url.Parse(apihost)
+	if err != nil {
+		return err
+	}
+	if u.Host == "" {
+		return errors.New("missing host")
+	}
+	return nil
+}
+
+func validatePort(port int) error {
+	if port < 1 || port > 65535 {
+		return fmt.Errorf("%d is not a valid port", port)
+	}
+	return nil
+}
+
+// parsePortRange parses "min-max", or a single port; empty means any port.
+func parsePortRange(value string) (tcp.PortRange, error) {
+	if value == "" {
+		return tcp.PortRange{}, nil
+	}
+	first, last, found := strings.Cut(value, "-")
+	if !found {
+		last = first
+	}
+	min, errMin := strconv.Atoi(strings.TrimSpace(first))
+	max, errMax := strconv.Atoi(strings.TrimSpace(last))
+	if errMin != nil || errMax != nil {
+		return tcp.PortRange{}, fmt.Errorf("%q is not a port range like 32000-32099", value)
+	}
+	for _, port := range []int{min, max} {
+		if err := validatePort(port); err != nil {
+			return tcp.PortRange{}, err
+		}
+	}
+	if min > max {
+		return tcp.PortRange{}, fmt.Errorf("%q starts after its end", value)
+	}
+	return tcp.PortRange{Min: min, Max: max}, nil
+}
+
+// BindAddr is the address the action sockets listen on.
+func (c *Config) BindAddr() string {
+	if c.Stream.BindAddr != "" {
+		return c.Stream.BindAddr
+	}
+	return c.StreamerAddr
+}
+
+// OpenWhiskHost is the API host the actions are invoked on, empty when they
+// run on another backend.
+func (c *Config) OpenWhiskHost() string {
+	if c.Backend.Type != "openwhisk" {
+		return ""
+	}
+	return c.APIHost
+}
+
+// Ports is the port range of the action sockets, valid once the
+// configuration is validated.
+func (s StreamConfig) Ports() tcp.PortRange {
+	ports, _ := parsePortRange(s.PortRange)
+	return ports
+}
+
+// parseSocketMode parses permissions in octal, as 0660.
+func parseSocketMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("%q is not a file mode like 0660", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// SocketFileMode is the permissions of the Unix sockets, valid once the
+// configuration is validated.
+func (s StreamConfig) SocketFileMode() os.FileMode {
+	mode, _ := parseSocketMode(s.SocketMode)
+	return mode
+}
+
+func (l LimitsConfig) Limiter() limiter.Config {
+	convert := func(l Limit) limiter.Limit {
+		return limiter.Limit{Rate: l.Rate, Burst: l.Burst, MaxStreams: l.Streams}
+	}
+	return limiter.Config{
+		Global:    convert(l.Global),
+		Namespace: convert(l.Namespace),
+		APIKey:    convert(l.APIKey),
+	}
+}
+
+// Redacted returns a copy of the configuration without secrets.
+func (c *Config) Redacted() *Config {
+	redacted := *c
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "***"
+	}
+	return &redacted
+}
+
+func (c *Config) YAML() ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), encoder.Close()
+}
+
+// Duration accepts Go durations ("1m30s") or a plain number of seconds.
+type Duration time.Duration
+
+func (d Duration) Duration() time.Duration {
+	return time.Duration(d)
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d *Duration) UnmarshalYAML(value *yaml.Node) error {
+	parsed, err := parseDuration(value.Value)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", value.Line, err)
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalYAML() (interface{}, error) {
+	return d.String(), nil
+}
+
+func parseDuration(v string) (time.Duration, error) {
+	if seconds, err := strconv.Atoi(v); err == nil {
+		return time.Duration(seconds) * time.Second, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a valid duration", v)
+	}
+	return d, nil
+}
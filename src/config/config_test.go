@@ -0,0 +1,509 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, name string, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func envMap(env map[string]string) func(string) string {
+	return func(key string) string { return env[key] }
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeConfigFile(t, "streamer.yaml", `
+apihost: https://file.example.com
+streamer_addr: 10.0.0.1
+http:
+  port: 8080
+cors:
+  enabled: true
+  allow_origin: https://app.example.com
+limits:
+  namespace:
+    rate: 2.5
+    streams: 10
+timeouts:
+  shutdown: 1m
+`)
+
+	env := map[string]string{
+		"STREAMER_CONFIG":  path,
+		"HTTP_SERVER_PORT": "9090",
+		"DRAIN_DELAY":      "5",
+	}
+	loader, err := newLoader([]string{"--apihost", "https://flag.example.com", "--cors=false"}, envMap(env), io.Discard)
+	require.NoError(t, err)
+	require.Equal(t, path, loader.Path)
+
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	require.Equal(t, "https://flag.example.com", cfg.APIHost)
+	require.Equal(t, "10.0.0.1", cfg.StreamerAddr)
+	require.Equal(t, 9090, cfg.HTTP.Port)
+	require.False(t, cfg.CORS.Enabled)
+	require.Equal(t, "https://app.example.com", cfg.CORS.AllowOrigin)
+	require.Equal(t, "GET, POST, OPTIONS", cfg.CORS.AllowMethods)
+	require.Equal(t, 2.5, cfg.Limits.Namespace.Rate)
+	require.Equal(t, 10, cfg.Limits.Limiter().Namespace.MaxStreams)
+	require.Equal(t, time.Minute, cfg.Timeouts.Shutdown.Duration())
+	require.Equal(t, 5*time.Second, cfg.Timeouts.DrainDelay.Duration())
+	require.Equal(t, 10*time.Second, cfg.Timeouts.ReadHeader.Duration())
+	require.True(t, cfg.Routes.Action)
+}
+
+func TestLoadJSONFile(t *testing.T) {
+	path := writeConfigFile(t, "streamer.json", `{"apihost": "localhost:3233", "streamer_addr": "localhost", "routes": {"action": false, "web": true}}`)
+
+	loader, err := newLoader([]string{"--config", path}, envMap(nil), io.Discard)
+	require.NoError(t, err)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, "localhost:3233", cfg.APIHost)
+	require.False(t, cfg.Routes.Action)
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		file     string
+		env      map[string]string
+		expected []string
+	}{
+		{
+			name:     "missing required",
+			expected: []string{"apihost is required", "streamer_addr is required"},
+		},
+		{
+			name: "invalid env values",
+			env: map[string]string{
+				"OW_APIHOST":        "localhost",
+				"STREAMER_ADDR":     "localhost",
+				"HTTP_SERVER_PORT":  "eighty",
+				"CORS_ENABLED":      "maybe",
+				"SHUTDOWN_TIMEOUT":  "soon",
+				"LIMIT_GLOBAL_RATE": "fast",
+			},
+			expected: []string{
+				`HTTP_SERVER_PORT: "eighty" is not a valid number`,
+				`CORS_ENABLED: "maybe" is not a valid boolean`,
+				`SHUTDOWN_TIMEOUT: "soon" is not a valid duration`,
+				`LIMIT_GLOBAL_RATE: "fast" is not a valid number`,
+			},
+		},
+		{
+			name: "invalid values",
+			env: map[string]string{
+				"OW_APIHOST":        "localhost",
+				"STREAMER_ADDR":     "localhost",
+				"HTTP_SERVER_PORT":  "70000",
+				"ADMIN_SERVER_PORT": "8081",
+				"GRPC_SERVER_PORT":  "8081",
+				"LIMIT_APIKEY_RATE": "-1",
+			},
+			expected: []string{
+				"http.port: 70000 is not a valid port",
+				"admin.token is required",
+				"grpc.port must differ from http.port and admin.port",
+				"limits.apikey: values must not be negative",
+			},
+		},
+		{
+			name: "invalid tls",
+			env: map[string]string{
+				"OW_APIHOST":           "localhost",
+				"STREAMER_ADDR":        "localhost",
+				"HTTP_TLS_KEY_FILE":    "tls.key",
+				"HTTP_TLS_CLIENT_AUTH": "require",
+			},
+			expected: []string{
+				"http.tls: cert_file and key_file must be set together",
+				"http.tls: client_auth requires cert_file and key_file",
+				"http.tls: client_auth require requires client_ca_file",
+			},
+		},
+		{
+			name: "invalid stream sockets",
+			env: map[string]string{
+				"OW_APIHOST":              "localhost",
+				"STREAMER_ADDR":           "localhost",
+				"STREAM_BIND_ADDR":        "::",
+				"STREAM_PORT_RANGE":       "32100-32000",
+				"STREAM_SOCKET_MODE":      "rw-rw----",
+				"STREAM_INGEST_BASE_URL":  "streamer:8080",
+				"STREAM_HEARTBEAT_FORMAT": "json",
+				"STREAM_IDLE_TIMEOUT":     "-1s",
+			},
+			expected: []string{
+				"stream.advertise_host is required when binding to ::",
+				`stream.port_range: "32100-32000" starts after its end`,
+				`stream.socket_mode: "rw-rw----" is not a file mode like 0660`,
+				`stream.ingest_base_url "streamer:8080" is not an http or https URL`,
+				`stream.heartbeat.format "json" is not one of newline, sse`,
+				"stream.idle_timeout must not be negative",
+			},
+		},
+		{
+			name: "invalid backends",
+			env: map[string]string{
+				"STREAMER_ADDR":       "localhost",
+				"BACKEND_TYPE":        "webhook",
+				"BACKEND_WEBHOOK_URL": "knative.local/{action}",
+			},
+			expected: []string{`backend.webhook.url "knative.local/{action}" is not an http or https URL`},
+		},
+		{
+			name: "unknown backend",
+			env: map[string]string{
+				"OW_APIHOST":    "localhost",
+				"STREAMER_ADDR": "localhost",
+				"BACKEND_TYPE":  "lambda",
+			},
+			expected: []string{`backend.type "lambda" is not one of openwhisk, webhook, process`},
+		},
+		{
+			name: "invalid cors",
+			file: `apihost: localhost
+streamer_addr: localhost
+cors:
+  enabled: true
+  allow_origin: "*"
+  allow_credentials: true
+  routes:
+    - path: web
+      allow_origin: "https://app.example.com/path, app.example.com, https://*.*.example.com"
+      max_age: -1s
+`,
+			expected: []string{
+				"cors: allow_credentials requires the origins to be listed, not *",
+				`cors.routes[0]: path "web" must start with /`,
+				`cors.routes[0]: origin "https://app.example.com/path" is not an origin`,
+				`cors.routes[0]: origin "app.example.com" is not an origin`,
+				`cors.routes[0]: origin "https://*.*.example.com" is not an origin`,
+				"cors.routes[0]: max_age must not be negative",
+			},
+		},
+		{
+			name: "invalid auth",
+			env: map[string]string{
+				"OW_APIHOST":    "localhost",
+				"STREAMER_ADDR": "localhost",
+				"AUTH_MODE":     "vault",
+			},
+			expected: []string{
+				"auth.callers_file is required in vault mode",
+				"auth.vault: file or dir is required in vault mode",
+			},
+		},
+		{
+			name: "unknown auth mode",
+			env: map[string]string{
+				"OW_APIHOST":    "localhost",
+				"STREAMER_ADDR": "localhost",
+				"AUTH_MODE":     "oauth",
+			},
+			expected: []string{`auth.mode "oauth" is not one of passthrough, vault, jwt`},
+		},
+		{
+			name: "invalid jwt",
+			env: map[string]string{
+				"OW_APIHOST":      "localhost",
+				"STREAMER_ADDR":   "localhost",
+				"AUTH_MODE":       "jwt",
+				"AUTH_JWT_ISSUER": "id.example.com",
+			},
+			expected: []string{
+				`auth.jwt.issuer "id.example.com" is not an http or https URL`,
+				"auth.jwt.rules: a rule with {ns} is required",
+				"auth.vault: file or dir is required in jwt mode",
+			},
+		},
+		{
+			name: "jwt rules not bound to the namespace",
+			file: `apihost: localhost
+streamer_addr: localhost
+auth:
+  mode: jwt
+  vault:
+    dir: /var/run/secrets/openwhisk
+  jwt:
+    issuer: https://id.example.com
+    rules:
+      scope: stream:invoke
+`,
+			expected: []string{"auth.jwt.rules: a rule with {ns} is required"},
+		},
+		{
+			name: "invalid routes",
+			file: `apihost: localhost
+streamer_addr: localhost
+routes:
+  table:
+    - {path: "/chat/{model}", namespace: prod, action: llm/chat, type: web}
+    - {path: "/chat/{model}", action: llm/chat, type: blocking, methods: [put], format: sse, params: {STREAM_HOST: evil.com}}
+    - {path: /healthz, namespace: prod, action: llm/chat, type: action}
+    - {path: /action/prod, namespace: prod, action: llm/chat, type: action}
+params:
+  max_body_size: -1
+  reserved: ignore
+record:
+  dir: /tmp/recordings
+  max_age: -1s
+`,
+			expected: []string{
+				`routes.table[0]: path "/chat/{model}" must start with / and have no wildcards`,
+				"routes.table[1]: namespace and action are required",
+				`routes.table[1]: type "blocking" is not one of action, web`,
+				`routes.table[1]: method "PUT" is not one of GET, POST`,
+				`routes.table[1]: format "sse" is not one of text, ndjson`,
+				`routes.table[1]: path "/chat/{model}" is already routed`,
+				`routes.table[1]: param "STREAM_HOST" is reserved to the streamer`,
+				`routes.table[2]: path "/healthz" is served by the streamer`,
+				`routes.table[3]: path "/action/prod" is served by the streamer`,
+				"params.max_body_size must not be negative",
+				`params.reserved "ignore" is not one of reject, namespace`,
+				"record: max_streams, max_size and max_age must not be negative",
+			},
+		},
+		{
+			name:     "unknown key in file",
+			file:     "apihost: localhost\nstreamer_adr: localhost\n",
+			expected: []string{"field streamer_adr not found"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := []string{}
+			if tt.file != "" {
+				args = append(args, "--config", writeConfigFile(t, "streamer.yaml", tt.file))
+			}
+			loader, err := newLoader(args, envMap(tt.env), io.Discard)
+			require.NoError(t, err)
+
+			_, err = loader.Load()
+			require.Error(t, err)
+			for _, msg := range tt.expected {
+				require.ErrorContains(t, err, msg)
+			}
+		})
+	}
+}
+
+func TestCORSOverride(t *testing.T) {
+	cors := Default().CORS
+	cors.AllowOrigin = "https://app.example.com, https://*.example.com"
+	cors.AllowCredentials = true
+	require.Equal(t, []string{"https://app.example.com", "https://*.example.com"}, cors.Origins())
+
+	noCredentials := false
+	maxAge := Duration(time.Hour)
+	web := cors.Override(CORSRoute{Path: "/web/", AllowOrigin: "*", AllowCredentials: &noCredentials, MaxAge: &maxAge})
+	require.Equal(t, []string{"*"}, web.Origins())
+	require.False(t, web.AllowCredentials)
+	require.Equal(t, time.Hour, web.MaxAge.Duration())
+	require.Equal(t, cors.AllowMethods, web.AllowMethods)
+	require.Equal(t, cors.ExposeHeaders, web.ExposeHeaders)
+
+	inherited := cors.Override(CORSRoute{Path: "/action/"})
+	require.True(t, inherited.AllowCredentials)
+	require.Equal(t, cors.AllowOrigin, inherited.AllowOrigin)
+}
+
+func TestJWTAuth(t *testing.T) {
+	path := writeConfigFile(t, "streamer.yaml", `apihost: localhost
+streamer_addr: localhost
+auth:
+  mode: jwt
+  vault:
+    dir: /var/run/secrets/openwhisk
+  jwt:
+    issuer: https://id.example.com
+    rules:
+      namespace: "{ns}"
+      scope: stream:invoke
+    forward_claims: [sub, email]
+`)
+	loader, err := newLoader([]string{"--config", path}, envMap(nil), io.Discard)
+	require.NoError(t, err)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	require.Equal(t, map[string]string{"namespace": "{ns}", "scope": "stream:invoke"}, cfg.Auth.JWT.Rules)
+	require.Equal(t, []string{"sub", "email"}, cfg.Auth.JWT.ForwardClaims)
+	require.Equal(t, time.Hour, cfg.Auth.JWT.KeysTTL.Duration())
+}
+
+func TestRouteTable(t *testing.T) {
+	path := writeConfigFile(t, "streamer.yaml", `apihost: localhost
+streamer_addr: localhost
+routes:
+  action: false
+  web: false
+  table:
+    - path: /chat
+      namespace: prod
+      action: llm/chat
+      type: web
+      methods: [post]
+      format: ndjson
+      params:
+        model: small
+        temperature: 0.2
+      schema: chat.schema.yaml
+`)
+	loader, err := newLoader([]string{"--config", path}, envMap(nil), io.Discard)
+	require.NoError(t, err)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+
+	require.Len(t, cfg.Routes.Table, 1)
+	route := cfg.Routes.Table[0]
+	require.Equal(t, []string{"POST"}, route.AllowedMethods())
+	require.Equal(t, map[string]interface{}{"model": "small", "temperature": 0.2}, route.Params)
+	require.Equal(t, "chat.schema.yaml", route.Schema)
+	require.Equal(t, []string{"GET", "POST"}, RouteConfig{}.AllowedMethods())
+}
+
+func TestStreamSockets(t *testing.T) {
+	cfg := Default()
+	cfg.StreamerAddr = "10.0.0.1"
+	require.Equal(t, "10.0.0.1", cfg.BindAddr())
+	require.Equal(t, tcp.PortRange{}, cfg.Stream.Ports())
+
+	cfg.Stream.BindAddr = "0.0.0.0"
+	cfg.Stream.AdvertiseHost = "streamer.example.com"
+	cfg.Stream.PortRange = "32000-32099"
+	require.Equal(t, "0.0.0.0", cfg.BindAddr())
+	require.Equal(t, tcp.PortRange{Min: 32000, Max: 32099}, cfg.Stream.Ports())
+
+	tests := map[string]string{
+		"32000":       "",
+		" 1 - 2 ":     "",
+		"32000-70000": "70000 is not a valid port",
+		"low-high":    "is not a port range",
+	}
+	for value, expected := range tests {
+		_, err := parsePortRange(value)
+		if expected == "" {
+			require.NoError(t, err, value)
+		} else {
+			require.ErrorContains(t, err, expected, value)
+		}
+	}
+
+	// the actions connecting to Unix sockets need no streamer_addr
+	loader, err := newLoader(nil, envMap(map[string]string{"OW_APIHOST": "localhost", "STREAM_SOCKET_DIR": t.TempDir()}), io.Discard)
+	require.NoError(t, err)
+	_, err = loader.Load()
+	require.NoError(t, err)
+}
+
+func TestBackend(t *testing.T) {
+	loader, err := newLoader([]string{"--backend", "process", "--backend-command", "python3 ex.py"}, envMap(map[string]string{
+		"STREAMER_ADDR": "localhost",
+	}), io.Discard)
+	require.NoError(t, err)
+
+	// apihost is only needed by OpenWhisk
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, "python3 ex.py", cfg.Backend.Process.Command)
+	require.Empty(t, cfg.OpenWhiskHost())
+
+	cfg.APIHost = "localhost:3233"
+	require.Empty(t, cfg.OpenWhiskHost())
+	cfg.Backend.Type = "openwhisk"
+	require.Equal(t, "localhost:3233", cfg.OpenWhiskHost())
+}
+
+func TestDevMode(t *testing.T) {
+	file := writeConfigFile(t, "streamer.yaml", "dev:\n  actions:\n    hello: python3 hello.py\n")
+	loader, err := newLoader([]string{"--config", file, "--dev", "--dev-port", "3300"}, envMap(map[string]string{
+		"OW_APIHOST": "openwhisk.example.com",
+	}), io.Discard)
+	require.NoError(t, err)
+
+	// the emulator replaces OpenWhisk
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	require.Equal(t, "http://127.0.0.1:3300", cfg.APIHost)
+	require.Equal(t, "127.0.0.1", cfg.StreamerAddr)
+	require.Equal(t, "http://127.0.0.1:80", cfg.Stream.IngestBaseURL)
+	require.Equal(t, map[string]string{"hello": "python3 hello.py"}, cfg.Dev.Actions)
+}
+
+func TestNewLoaderInvalidFlag(t *testing.T) {
+	_, err := newLoader([]string{"--unknown"}, envMap(nil), io.Discard)
+	require.Error(t, err)
+
+	_, err = newLoader([]string{"extra"}, envMap(nil), io.Discard)
+	require.ErrorContains(t, err, "unexpected arguments")
+}
+
+func TestRedactedYAML(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Token = "secret"
+
+	out, err := cfg.Redacted().YAML()
+	require.NoError(t, err)
+	require.Contains(t, string(out), "token: '***'")
+	require.Contains(t, string(out), "shutdown: 30s")
+	require.NotContains(t, string(out), "secret")
+	require.Equal(t, "secret", cfg.Admin.Token)
+}
+
+func TestDiff(t *testing.T) {
+	old := Default()
+	old.Admin.Token = "old"
+	new := Default()
+	new.Admin.Token = "new"
+	new.HTTP.Port = 8080
+	new.CORS.AllowOrigin = "https://app.example.com"
+	new.Limits.Namespace.Rate = 2
+
+	changes, err := Diff(old, new)
+	require.NoError(t, err)
+	require.Len(t, changes, 4)
+
+	require.Equal(t, "admin.token changed", changes[0].String())
+	require.True(t, changes[0].RequiresRestart())
+	require.Equal(t, `cors.allow_origin: '*' -> https://app.example.com`, changes[1].String())
+	require.False(t, changes[1].RequiresRestart())
+	require.Equal(t, "http.port: 80 -> 8080", changes[2].String())
+	require.True(t, changes[2].RequiresRestart())
+	require.Equal(t, "limits.namespace.rate: 0 -> 2", changes[3].String())
+
+	changes, err = Diff(old, old)
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
@@ -0,0 +1,111 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// secretKeys are reported as changed without showing their values.
+var secretKeys = map[string]bool{
+	"admin.token": true,
+}
+
+// staticKeys only take effect after a restart.
+var staticKeys = []string{"http.", "admin.", "grpc.", "dev.", "timeouts.read_header"}
+
+// Change is a setting that differs between two configurations.
+type Change struct {
+	Key string
+	Old string
+	New string
+}
+
+func (c Change) String() string {
+	if secretKeys[c.Key] {
+		return c.Key + " changed"
+	}
+	return fmt.Sprintf("%s: %s -> %s", c.Key, c.Old, c.New)
+}
+
+// RequiresRestart tells whether the change only applies after a restart.
+func (c Change) RequiresRestart() bool {
+	for _, prefix := range staticKeys {
+		if strings.HasPrefix(c.Key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Diff lists the settings that differ between old and new, sorted by key.
+func Diff(old *Config, new *Config) ([]Change, error) {
+	oldValues, err := flatten(old)
+	if err != nil {
+		return nil, err
+	}
+	newValues, err := flatten(new)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := []Change{}
+	for key, value := range newValues {
+		if oldValue, ok := oldValues[key]; !ok || oldValue != value {
+			changes = append(changes, Change{Key: key, Old: oldValues[key], New: value})
+		}
+	}
+	for key, value := range oldValues {
+		if _, ok := newValues[key]; !ok {
+			changes = append(changes, Change{Key: key, Old: value})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes, nil
+}
+
+// flatten maps the dotted path of every leaf setting to its YAML value.
+func flatten(c *Config) (map[string]string, error) {
+	data, err := yaml.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var tree map[string]interface{}
+	if err := yaml.Unmarshal(data, &tree); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string)
+	flattenInto(values, "", tree)
+	return values, nil
+}
+
+func flattenInto(values map[string]string, prefix string, node interface{}) {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flattenInto(values, prefix+key+".", child)
+		}
+	default:
+		out, _ := yaml.Marshal(v)
+		values[strings.TrimSuffix(prefix, ".")] = strings.TrimSpace(string(out))
+	}
+}
@@ -0,0 +1,259 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"gopkg.in/yaml.v3"
+)
+
+// setting is a configuration value that can be overridden by an environment
+// variable and, when flag is set, by a command line flag.
+type setting struct {
+	env    string
+	flag   string
+	usage  string
+	isBool bool
+	set    func(c *Config, v string) error
+}
+
+var settings = []setting{
+	stringSetting("OW_APIHOST", "apihost", "OpenWhisk API host", func(c *Config) *string { return &c.APIHost }),
+	stringSetting("STREAMER_ADDR", "streamer-addr", "address the actions connect to", func(c *Config) *string { return &c.StreamerAddr }),
+	intSetting("HTTP_SERVER_PORT", "http-port", "port of the HTTP server", func(c *Config) *int { return &c.HTTP.Port }),
+	stringSetting("HTTP_TLS_CERT_FILE", "tls-cert", "certificate file to serve HTTPS", func(c *Config) *string { return &c.HTTP.TLS.CertFile }),
+	stringSetting("HTTP_TLS_KEY_FILE", "tls-key", "key file to serve HTTPS", func(c *Config) *string { return &c.HTTP.TLS.KeyFile }),
+	stringSetting("HTTP_TLS_CLIENT_CA_FILE", "tls-client-ca", "CA bundle to verify client certificates", func(c *Config) *string { return &c.HTTP.TLS.ClientCAFile }),
+	stringSetting("HTTP_TLS_CLIENT_AUTH", "tls-client-auth", "client certificates: none, request or require", func(c *Config) *string { return &c.HTTP.TLS.ClientAuth }),
+	stringSetting("STREAM_BIND_ADDR", "stream-bind-addr", "address the action sockets listen on", func(c *Config) *string { return &c.Stream.BindAddr }),
+	stringSetting("STREAM_ADVERTISE_HOST", "stream-advertise-host", "host given to the actions to connect to", func(c *Config) *string { return &c.Stream.AdvertiseHost }),
+	stringSetting("STREAM_PORT_RANGE", "stream-port-range", "ports of the action sockets, as min-max", func(c *Config) *string { return &c.Stream.PortRange }),
+	stringSetting("STREAM_INGEST_BASE_URL", "stream-ingest-url", "URL the actions reach the streamer at over HTTP", func(c *Config) *string { return &c.Stream.IngestBaseURL }),
+	stringSetting("STREAM_SOCKET_DIR", "stream-socket-dir", "directory of the Unix sockets for co-located actions", func(c *Config) *string { return &c.Stream.SocketDir }),
+	stringSetting("STREAM_SOCKET_MODE", "stream-socket-mode", "permissions of the Unix sockets, in octal", func(c *Config) *string { return &c.Stream.SocketMode }),
+	stringSetting("STREAM_SOCKET_GROUP", "stream-socket-group", "group of the Unix sockets, a name or a gid", func(c *Config) *string { return &c.Stream.SocketGroup }),
+	durationSetting("STREAM_HEARTBEAT_INTERVAL", "stream-heartbeat", "keepalive interval to the clients, 0 to disable it", func(c *Config) *Duration { return &c.Stream.Heartbeat.Interval }),
+	stringSetting("STREAM_HEARTBEAT_FORMAT", "", "", func(c *Config) *string { return &c.Stream.Heartbeat.Format }),
+	durationSetting("STREAM_IDLE_TIMEOUT", "stream-idle-timeout", "end the streams idle for this long, 0 to disable it", func(c *Config) *Duration { return &c.Stream.IdleTimeout }),
+	durationSetting("STREAM_TCP_KEEPALIVE", "", "", func(c *Config) *Duration { return &c.Stream.TCPKeepAlive }),
+	stringSetting("STREAM_TLS_CERT_FILE", "stream-tls-cert", "certificate file for the action sockets", func(c *Config) *string { return &c.Stream.TLS.CertFile }),
+	stringSetting("STREAM_TLS_KEY_FILE", "stream-tls-key", "key file for the action sockets", func(c *Config) *string { return &c.Stream.TLS.KeyFile }),
+	intSetting("ADMIN_SERVER_PORT", "admin-port", "port of the admin server, 0 to disable it", func(c *Config) *int { return &c.Admin.Port }),
+	stringSetting("ADMIN_TOKEN", "", "", func(c *Config) *string { return &c.Admin.Token }),
+	stringSetting("BACKEND_TYPE", "backend", "what runs the actions: openwhisk, webhook or process", func(c *Config) *string { return &c.Backend.Type }),
+	stringSetting("BACKEND_WEBHOOK_URL", "backend-webhook-url", "URL the webhook backend posts the actions to", func(c *Config) *string { return &c.Backend.Webhook.URL }),
+	stringSetting("BACKEND_PROCESS_COMMAND", "backend-command", "command the process backend runs the actions with", func(c *Config) *string { return &c.Backend.Process.Command }),
+	stringSetting("BACKEND_PROCESS_DIR", "", "", func(c *Config) *string { return &c.Backend.Process.Dir }),
+	stringSetting("AUTH_MODE", "auth", "how the callers authenticate: passthrough, vault or jwt", func(c *Config) *string { return &c.Auth.Mode }),
+	stringSetting("AUTH_QUERY", "", "", func(c *Config) *string { return &c.Auth.Query }),
+	stringSetting("AUTH_COOKIE", "", "", func(c *Config) *string { return &c.Auth.Cookie }),
+	stringSetting("AUTH_CALLERS_FILE", "auth-callers", "API keys of the callers, in vault mode", func(c *Config) *string { return &c.Auth.CallersFile }),
+	stringSetting("AUTH_VAULT_FILE", "auth-vault-file", "OpenWhisk keys by namespace, in vault mode", func(c *Config) *string { return &c.Auth.Vault.File }),
+	stringSetting("AUTH_VAULT_DIR", "auth-vault-dir", "directory with the OpenWhisk key of each namespace, in vault mode", func(c *Config) *string { return &c.Auth.Vault.Dir }),
+	stringSetting("AUTH_JWT_JWKS_FILE", "auth-jwks", "JWKS file to verify the tokens with, in jwt mode", func(c *Config) *string { return &c.Auth.JWT.JWKSFile }),
+	stringSetting("AUTH_JWT_ISSUER", "auth-issuer", "issuer of the tokens, whose keys are fetched without a JWKS file", func(c *Config) *string { return &c.Auth.JWT.Issuer }),
+	stringSetting("AUTH_JWT_AUDIENCE", "", "", func(c *Config) *string { return &c.Auth.JWT.Audience }),
+	boolSetting("STREAMER_DEV", "dev", "run the actions locally, on an OpenWhisk emulator", func(c *Config) *bool { return &c.Dev.Enabled }),
+	intSetting("DEV_PORT", "dev-port", "port of the OpenWhisk emulator", func(c *Config) *int { return &c.Dev.Port }),
+	intSetting("GRPC_SERVER_PORT", "grpc-port", "port of the gRPC server, 0 to disable it", func(c *Config) *int { return &c.GRPC.Port }),
+	boolSetting("CORS_ENABLED", "cors", "enable the CORS handler", func(c *Config) *bool { return &c.CORS.Enabled }),
+	stringSetting("CORS_ALLOW_ORIGIN", "", "", func(c *Config) *string { return &c.CORS.AllowOrigin }),
+	stringSetting("CORS_ALLOW_METHODS", "", "", func(c *Config) *string { return &c.CORS.AllowMethods }),
+	stringSetting("CORS_ALLOW_HEADERS", "", "", func(c *Config) *string { return &c.CORS.AllowHeaders }),
+	boolSetting("CORS_ALLOW_CREDENTIALS", "", "", func(c *Config) *bool { return &c.CORS.AllowCredentials }),
+	stringSetting("CORS_EXPOSE_HEADERS", "", "", func(c *Config) *string { return &c.CORS.ExposeHeaders }),
+	durationSetting("CORS_MAX_AGE", "", "", func(c *Config) *Duration { return &c.CORS.MaxAge }),
+	floatSetting("LIMIT_GLOBAL_RATE", "", "", func(c *Config) *float64 { return &c.Limits.Global.Rate }),
+	intSetting("LIMIT_GLOBAL_BURST", "", "", func(c *Config) *int { return &c.Limits.Global.Burst }),
+	intSetting("LIMIT_GLOBAL_STREAMS", "", "", func(c *Config) *int { return &c.Limits.Global.Streams }),
+	floatSetting("LIMIT_NAMESPACE_RATE", "", "", func(c *Config) *float64 { return &c.Limits.Namespace.Rate }),
+	intSetting("LIMIT_NAMESPACE_BURST", "", "", func(c *Config) *int { return &c.Limits.Namespace.Burst }),
+	intSetting("LIMIT_NAMESPACE_STREAMS", "", "", func(c *Config) *int { return &c.Limits.Namespace.Streams }),
+	floatSetting("LIMIT_APIKEY_RATE", "", "", func(c *Config) *float64 { return &c.Limits.APIKey.Rate }),
+	intSetting("LIMIT_APIKEY_BURST", "", "", func(c *Config) *int { return &c.Limits.APIKey.Burst }),
+	intSetting("LIMIT_APIKEY_STREAMS", "", "", func(c *Config) *int { return &c.Limits.APIKey.Streams }),
+	intSetting("PARAMS_MAX_BODY_SIZE", "max-body-size", "largest JSON body accepted, in bytes, 0 for no limit", func(c *Config) *int { return &c.Params.MaxBodySize }),
+	stringSetting("PARAMS_RESERVED", "", "", func(c *Config) *string { return &c.Params.Reserved }),
+	stringSetting("RECORD_DIR", "record-dir", "directory to record the streams to, to replay them", func(c *Config) *string { return &c.Record.Dir }),
+	intSetting("RECORD_MAX_STREAMS", "", "", func(c *Config) *int { return &c.Record.MaxStreams }),
+	intSetting("RECORD_MAX_SIZE", "", "", func(c *Config) *int { return &c.Record.MaxSize }),
+	durationSetting("RECORD_MAX_AGE", "", "", func(c *Config) *Duration { return &c.Record.MaxAge }),
+	durationSetting("READ_HEADER_TIMEOUT", "", "", func(c *Config) *Duration { return &c.Timeouts.ReadHeader }),
+	durationSetting("DRAIN_DELAY", "drain-delay", "time to fail readiness before shutting down", func(c *Config) *Duration { return &c.Timeouts.DrainDelay }),
+	durationSetting("SHUTDOWN_TIMEOUT", "shutdown-timeout", "time to wait for active streams on shutdown", func(c *Config) *Duration { return &c.Timeouts.Shutdown }),
+}
+
+// Loader builds the configuration from the defaults, the config file, the
+// environment and the command line flags, each overriding the previous one.
+type Loader struct {
+	Path        string
+	PrintConfig bool
+
+	flags  map[string]string
+	getenv func(string) string
+}
+
+// NewLoader parses the command line arguments.
+func NewLoader(args []string) (*Loader, error) {
+	return newLoader(args, os.Getenv, os.Stderr)
+}
+
+func newLoader(args []string, getenv func(string) string, output io.Writer) (*Loader, error) {
+	l := &Loader{
+		flags:  make(map[string]string),
+		getenv: getenv,
+	}
+
+	fs := flag.NewFlagSet("streamer", flag.ContinueOnError)
+	fs.SetOutput(output)
+	fs.StringVar(&l.Path, "config", getenv("STREAMER_CONFIG"), "path of the YAML or JSON config file (STREAMER_CONFIG)")
+	fs.BoolVar(&l.PrintConfig, "print-config", false, "print the resulting configuration and exit")
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		name := s.flag
+		usage := fmt.Sprintf("%s (%s)", s.usage, s.env)
+		store := func(v string) error {
+			l.flags[name] = v
+			return nil
+		}
+		if s.isBool {
+			fs.BoolFunc(name, usage, store)
+		} else {
+			fs.Func(name, usage, store)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %v", fs.Args())
+	}
+	return l, nil
+}
+
+// Load builds and validates the configuration.
+func (l *Loader) Load() (*Config, error) {
+	cfg := Default()
+
+	if l.Path != "" {
+		if err := loadFile(cfg, l.Path); err != nil {
+			return nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range settings {
+		if v := l.getenv(s.env); v != "" {
+			if err := s.set(cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, s := range settings {
+		if v, ok := l.flags[s.flag]; ok && s.flag != "" {
+			if err := s.set(cfg, v); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", s.flag, err))
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	cfg.applyDev()
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile reads a YAML config file, which can also be plain JSON.
+// Unknown keys are reported, to catch typos.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(cfg); err != nil && err != io.EOF {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	return nil
+}
+
+func stringSetting(env string, flag string, usage string, field func(*Config) *string) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		*field(c) = v
+		return nil
+	}}
+}
+
+func intSetting(env string, flag string, usage string, field func(*Config) *int) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func floatSetting(env string, flag string, usage string, field func(*Config) *float64) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid number", v)
+		}
+		*field(c) = n
+		return nil
+	}}
+}
+
+func boolSetting(env string, flag string, usage string, field func(*Config) *bool) setting {
+	return setting{env: env, flag: flag, usage: usage, isBool: true, set: func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("%q is not a valid boolean", v)
+		}
+		*field(c) = b
+		return nil
+	}}
+}
+
+func durationSetting(env string, flag string, usage string, field func(*Config) *Duration) setting {
+	return setting{env: env, flag: flag, usage: usage, set: func(c *Config, v string) error {
+		d, err := parseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(c) = Duration(d)
+		return nil
+	}}
+}
@@ -0,0 +1,54 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apache/openserverless-streaming-proxy/config"
+	"github.com/apache/openserverless-streaming-proxy/devserver"
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+)
+
+// startDevServer runs the OpenWhisk emulator of the dev mode, on the
+// loopback interface only, with the actions of the configuration, run as
+// by the process backend until their stream in registry is over.
+func startDevServer(dev config.DevConfig, registry *streams.Registry) error {
+	server := devserver.New(registry)
+	for name, command := range dev.Actions {
+		server.Register(name, devserver.InvokerAction(name, &handlers.ProcessInvoker{Command: strings.Fields(command)}))
+		log.Printf("Dev action %s: %s", name, command)
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(dev.Port)))
+	if err != nil {
+		return err
+	}
+	log.Println("Dev mode: OpenWhisk emulator listening on", listener.Addr())
+	go func() {
+		if err := http.Serve(listener, server); err != nil {
+			log.Println("Error serving the OpenWhisk emulator:", err)
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,257 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package devserver is a minimal OpenWhisk controller, serving the invoke and
+// web action endpoints the streamer calls, to develop streaming actions
+// without a cluster. The actions are Go functions, or run by an invoker of
+// the streamer, as the local commands of the process backend.
+package devserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+)
+
+// Action runs an action of namespace with its parameters, returning its
+// result.
+type Action func(ctx context.Context, namespace string, params map[string]interface{}) (map[string]interface{}, error)
+
+// InvokerAction runs the action name with invoker, the STREAM_* parameters
+// being those to stream. Its result is empty, as the invokers return none.
+func InvokerAction(name string, invoker handlers.Invoker) Action {
+	return func(ctx context.Context, namespace string, params map[string]interface{}) (map[string]interface{}, error) {
+		streamParams := make(map[string]string)
+		for key, value := range params {
+			if s, ok := value.(string); ok && strings.HasPrefix(key, "STREAM_") {
+				streamParams[key] = s
+			}
+		}
+		results, err := invoker.Invoke(ctx, handlers.InvokeRequest{
+			Kind:         "action",
+			Namespace:    namespace,
+			Action:       name,
+			Params:       params,
+			StreamParams: streamParams,
+		})
+		if err != nil {
+			return nil, err
+		}
+		select {
+		case result := <-results:
+			if result.Err != nil {
+				return nil, fmt.Errorf("%s: %w", name, result.Err)
+			}
+			return map[string]interface{}{}, nil
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Server emulates the OpenWhisk controller for its actions. Authentication
+// is not checked.
+type Server struct {
+	mu       sync.RWMutex
+	actions  map[string]Action
+	registry *streams.Registry
+	router   *http.ServeMux
+}
+
+// New returns an emulator cancelling the non-blocking invocations once their
+// stream in registry is over. With a nil registry they run until done.
+func New(registry *streams.Registry) *Server {
+	s := &Server{
+		actions:  make(map[string]Action),
+		registry: registry,
+		router:   http.NewServeMux(),
+	}
+	s.router.HandleFunc("GET /api/v1", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"description": "OpenWhisk emulator of the streamer"})
+	})
+	s.router.HandleFunc("POST /api/v1/namespaces/{ns}/actions/{action...}", s.invoke)
+	s.router.HandleFunc("GET /api/v1/web/{ns}/{action...}", s.web)
+	s.router.HandleFunc("POST /api/v1/web/{ns}/{action...}", s.web)
+	return s
+}
+
+// Register makes action available as name, "action" or "package/action".
+func (s *Server) Register(name string, action Action) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.actions[actionName(name)] = action
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// actionName drops the default package, which can be omitted.
+func actionName(name string) string {
+	return strings.TrimPrefix(strings.Trim(name, "/"), "default/")
+}
+
+func (s *Server) lookup(name string) (Action, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	action, ok := s.actions[actionName(name)]
+	return action, ok
+}
+
+// invoke runs the action. Blocking invocations wait for its result, the
+// others only get the activation id, as the streamer does.
+func (s *Server) invoke(w http.ResponseWriter, r *http.Request) {
+	namespace, name := r.PathValue("ns"), r.PathValue("action")
+	action, ok := s.lookup(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "The requested resource does not exist."})
+		return
+	}
+	params, err := readParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	activationID := newActivationID()
+	log.Printf("Dev action %s invoked, activation %s", name, activationID)
+	if r.URL.Query().Get("blocking") != "true" {
+		ctx, cancel := s.streamContext(params)
+		go func() {
+			defer cancel()
+			run(ctx, namespace, name, activationID, action, params)
+		}()
+		writeJSON(w, http.StatusAccepted, map[string]string{"activationId": activationID})
+		return
+	}
+
+	result, err := run(r.Context(), namespace, name, activationID, action, params)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{
+			"activationId": activationID,
+			"response":     map[string]interface{}{"success": false, "result": map[string]string{"error": err.Error()}},
+		})
+		return
+	}
+	if r.URL.Query().Get("result") == "true" {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"activationId": activationID,
+		"response":     map[string]interface{}{"success": true, "result": result},
+	})
+}
+
+// web runs the web action until it is done, answering with its result.
+func (s *Server) web(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimSuffix(r.PathValue("action"), ".json")
+	action, ok := s.lookup(name)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "The requested resource does not exist."})
+		return
+	}
+	params, err := readParams(r)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	// the query parameters of web actions are parameters too
+	for key, values := range r.URL.Query() {
+		if _, ok := params[key]; !ok && len(values) > 0 {
+			params[key] = values[0]
+		}
+	}
+
+	activationID := newActivationID()
+	log.Printf("Dev web action %s invoked, activation %s", name, activationID)
+	w.Header().Set("X-Openwhisk-Activation-Id", activationID)
+	result, err := run(r.Context(), r.PathValue("ns"), name, activationID, action, params)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// streamContext is cancelled once the stream the action writes to, found by
+// the ingest URL in its parameters, is over.
+func (s *Server) streamContext(params map[string]interface{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+	ingestURL, _ := params["STREAM_INGEST_URL"].(string)
+	if s.registry == nil || ingestURL == "" {
+		return ctx, cancel
+	}
+	stream, ok := s.registry.Get(path.Base(ingestURL))
+	if !ok {
+		cancel()
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-stream.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
+
+func run(ctx context.Context, namespace string, name string, activationID string, action Action, params map[string]interface{}) (map[string]interface{}, error) {
+	result, err := action(ctx, namespace, params)
+	if err != nil {
+		log.Printf("Dev action %s failed, activation %s: %s", name, activationID, err)
+		return nil, err
+	}
+	log.Printf("Dev action %s done, activation %s", name, activationID)
+	return result, nil
+}
+
+func readParams(r *http.Request) (map[string]interface{}, error) {
+	params := make(map[string]interface{})
+	err := json.NewDecoder(r.Body).Decode(&params)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, errors.New("The request content was malformed: " + err.Error())
+	}
+	return params, nil
+}
+
+func newActivationID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println("Error encoding response:", err)
+	}
+}
@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/certs"
+	"github.com/apache/openserverless-streaming-proxy/streamerpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// startGRPCServer serves the gRPC API until ctx is done, then waits up to
+// the shutdown timeout for the active streams to end. It uses the HTTPS
+// certificate, when set.
+func startGRPCServer(ctx context.Context, rl *reloader) {
+	cfg := rl.Config()
+	grpcPort := strconv.Itoa(cfg.GRPC.Port)
+
+	var opts []grpc.ServerOption
+	if cfg.HTTP.TLS.Enabled() {
+		clientAuth, err := certs.ParseClientAuth(cfg.HTTP.TLS.ClientAuth)
+		if err != nil {
+			log.Println("Error starting gRPC server:", err)
+			return
+		}
+		reloader, err := certs.NewReloader(cfg.HTTP.TLS.CertFile, cfg.HTTP.TLS.KeyFile, cfg.HTTP.TLS.ClientCAFile)
+		if err != nil {
+			log.Println("Error starting gRPC server:", err)
+			return
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(reloader.ServerConfig(clientAuth))))
+	}
+
+	server := grpc.NewServer(opts...)
+	streamerpb.RegisterStreamerServer(server, rl.grpcServer)
+
+	listener, err := net.Listen("tcp", ":"+grpcPort)
+	if err != nil {
+		log.Println("Error starting gRPC server:", err)
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(rl.Config().Timeouts.Shutdown.Duration()):
+			server.Stop()
+		}
+	}()
+
+	log.Println("gRPC server listening on port", grpcPort)
+	if err := server.Serve(listener); err != nil {
+		log.Println("Error serving gRPC:", err)
+	}
+}
@@ -0,0 +1,91 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAdminStreams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+
+	registry := streams.NewRegistry()
+	stream := streams.NewStream("web", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	registry.Add(stream)
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /admin/streams", ListStreamsHandler(registry))
+	router.HandleFunc("GET /admin/streams/{id}", GetStreamHandler(registry))
+	router.HandleFunc("DELETE /admin/streams/{id}", TerminateStreamHandler(registry))
+	router.HandleFunc("GET /admin/sockets", SocketMetricsHandler())
+	server := httptest.NewServer(AdminAuth("secret", router))
+	defer server.Close()
+
+	do := func(method string, path string, token string) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		t.Cleanup(func() { resp.Body.Close() })
+		return resp
+	}
+
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/admin/streams", "").StatusCode)
+	require.Equal(t, http.StatusUnauthorized, do("GET", "/admin/streams", "wrong").StatusCode)
+
+	resp := do("GET", "/admin/streams", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var infos []streams.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&infos))
+	require.Len(t, infos, 1)
+	require.Equal(t, "default/hello", infos[0].Action)
+	require.False(t, infos[0].Connected)
+
+	resp = do("GET", "/admin/streams/"+stream.ID, "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var info streams.Info
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&info))
+	require.Equal(t, sock.Port, info.TCPPort)
+
+	require.Equal(t, http.StatusNotFound, do("GET", "/admin/streams/unknown", "secret").StatusCode)
+
+	resp = do("GET", "/admin/sockets", "secret")
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	var metrics tcp.Metrics
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&metrics))
+	require.Positive(t, metrics.Listening)
+	require.Positive(t, metrics.Opened)
+	require.Equal(t, http.StatusNoContent, do("DELETE", "/admin/streams/"+stream.ID, "secret").StatusCode)
+	require.Error(t, ctx.Err())
+	require.Equal(t, http.StatusNotFound, do("DELETE", "/admin/streams/unknown", "secret").StatusCode)
+}
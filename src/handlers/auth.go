@@ -0,0 +1,217 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/apache/openserverless-streaming-proxy/jwt"
+	"github.com/apache/openserverless-streaming-proxy/vault"
+)
+
+var (
+	// ErrUnauthenticated is returned for missing or unknown credentials.
+	ErrUnauthenticated = errors.New("invalid or missing credentials")
+	// ErrForbidden is returned for callers not allowed in the namespace.
+	ErrForbidden = errors.New("not allowed to invoke the actions of the namespace")
+)
+
+// Caller is an authenticated caller.
+type Caller struct {
+	// APIKey is the key to invoke the actions with, which the caller never
+	// sees. It is empty when the caller may only invoke web actions.
+	APIKey string
+	// Claims are the claims of its token given to the actions, nil without
+	// a token.
+	Claims map[string]interface{}
+	// Identity is the credential the caller was authenticated with, empty
+	// for anonymous callers. The API key limits apply to it.
+	Identity string
+}
+
+// Authenticator checks the credential of the callers, and that they may
+// invoke the actions of namespace.
+type Authenticator interface {
+	Authenticate(ctx context.Context, credential string, namespace string) (Caller, error)
+}
+
+// VaultAuthenticator authenticates the callers with their own API keys, and
+// invokes the actions with the OpenWhisk key of the namespace in the vault.
+type VaultAuthenticator struct {
+	Callers vault.Callers
+	Vault   *vault.Vault
+}
+
+func (a *VaultAuthenticator) Authenticate(ctx context.Context, credential string, namespace string) (Caller, error) {
+	known, allowed := a.Callers.Allowed(credential, namespace)
+	if !known {
+		return Caller{}, ErrUnauthenticated
+	}
+	if !allowed {
+		return Caller{}, ErrForbidden
+	}
+	return vaultCaller(a.Vault, namespace)
+}
+
+// JWTAuthenticator authenticates the callers with JSON Web Tokens, and
+// authorizes them with rules on their claims. The actions are invoked with
+// the OpenWhisk key of the namespace in Vault; without one, the callers may
+// only invoke web actions.
+type JWTAuthenticator struct {
+	Verifier *jwt.Verifier
+	// Rules maps claims to the value they must have, or include when they
+	// are lists or space separated, as scope. {ns} is replaced by the
+	// namespace.
+	Rules map[string]string
+	// Forward lists the claims given to the actions.
+	Forward []string
+	Vault   *vault.Vault
+}
+
+func (a *JWTAuthenticator) Authenticate(ctx context.Context, credential string, namespace string) (Caller, error) {
+	if credential == "" {
+		return Caller{}, ErrUnauthenticated
+	}
+	claims, err := a.Verifier.Verify(ctx, credential)
+	if err != nil {
+		return Caller{}, fmt.Errorf("%w: %s", ErrUnauthenticated, err)
+	}
+	for claim, value := range a.Rules {
+		value = strings.ReplaceAll(value, "{ns}", namespace)
+		if !slices.Contains(claims.Strings(claim), value) {
+			return Caller{}, fmt.Errorf("%w: claim %s is not %s", ErrForbidden, claim, value)
+		}
+	}
+
+	caller := Caller{Claims: make(map[string]interface{})}
+	if a.Vault != nil {
+		if caller, err = vaultCaller(a.Vault, namespace); err != nil {
+			return Caller{}, err
+		}
+		caller.Claims = make(map[string]interface{})
+	}
+	for _, claim := range a.Forward {
+		if value, ok := claims[claim]; ok {
+			caller.Claims[claim] = value
+		}
+	}
+	return caller, nil
+}
+
+// vaultCaller is a caller invoking the actions with the key of namespace.
+func vaultCaller(v *vault.Vault, namespace string) (Caller, error) {
+	key, err := v.Key(namespace)
+	if errors.Is(err, vault.ErrNoKey) {
+		return Caller{}, ErrForbidden
+	}
+	if err != nil {
+		return Caller{}, err
+	}
+	return Caller{APIKey: key}, nil
+}
+
+// authenticate returns the caller of an action: with the OpenWhisk key it
+// sent, or the one its authenticator gives for its own credential. On
+// failure, it answers the client and returns false.
+func (cfg StreamConfig) authenticate(w http.ResponseWriter, r *http.Request, namespace string) (Caller, bool) {
+	if cfg.Auth != nil {
+		return cfg.authorize(w, r, cfg.Auth, namespace)
+	}
+	apiKey, err := cfg.credential(r)
+	if err == nil && apiKey == "" {
+		err = errors.New("Missing API key")
+	} else if err == nil && !validAPIKey(apiKey) {
+		err = errors.New("Invalid API key, expected uuid:key")
+	}
+	if err != nil {
+		log.Println(err.Error())
+		unauthorized(w, err.Error())
+		return Caller{}, false
+	}
+	return Caller{APIKey: apiKey, Identity: apiKey}, true
+}
+
+// authenticateWeb returns the caller of a web action, anonymous without
+// WebAuth.
+func (cfg StreamConfig) authenticateWeb(w http.ResponseWriter, r *http.Request, namespace string) (Caller, bool) {
+	if cfg.WebAuth == nil {
+		return Caller{}, true
+	}
+	return cfg.authorize(w, r, cfg.WebAuth, namespace)
+}
+
+func (cfg StreamConfig) authorize(w http.ResponseWriter, r *http.Request, auth Authenticator, namespace string) (Caller, bool) {
+	credential, err := cfg.credential(r)
+	if err != nil {
+		unauthorized(w, err.Error())
+		return Caller{}, false
+	}
+	caller, err := auth.Authenticate(r.Context(), credential, namespace)
+	switch {
+	case err == nil:
+		caller.Identity = credential
+		return caller, true
+	case errors.Is(err, ErrUnauthenticated):
+		unauthorized(w, err.Error())
+	case errors.Is(err, ErrForbidden):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	default:
+		log.Printf("Authentication for namespace %s failed: %s", namespace, err)
+		http.Error(w, "Authentication failed", http.StatusInternalServerError)
+	}
+	return Caller{}, false
+}
+
+func unauthorized(w http.ResponseWriter, message string) {
+	w.Header().Add("WWW-Authenticate", `Bearer realm="streamer"`)
+	w.Header().Add("WWW-Authenticate", `Basic realm="streamer"`)
+	http.Error(w, message, http.StatusUnauthorized)
+}
+
+// addClaims gives the claims of the caller, if any, to the action.
+func addClaims(params map[string]interface{}, caller Caller) {
+	if caller.Claims != nil {
+		params["STREAM_CLAIMS"] = caller.Claims
+	}
+}
+
+// credential is the credential of the caller, from the Authorization
+// header or, without one, from the AuthQuery parameter or the AuthCookie
+// cookie, when set. It is empty when there is none.
+func (cfg StreamConfig) credential(r *http.Request) (string, error) {
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		return parseAuthorization(auth)
+	}
+	if cfg.AuthQuery != "" {
+		if value := r.URL.Query().Get(cfg.AuthQuery); value != "" {
+			return value, nil
+		}
+	}
+	if cfg.AuthCookie != "" {
+		if c, err := r.Cookie(cfg.AuthCookie); err == nil {
+			return c.Value, nil
+		}
+	}
+	return "", nil
+}
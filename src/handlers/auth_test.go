@@ -0,0 +1,231 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/jwt"
+	"github.com/apache/openserverless-streaming-proxy/vault"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPassthroughAuthentication(t *testing.T) {
+	cfg := StreamConfig{AuthQuery: "apikey", AuthCookie: "session"}
+	basic := base64.StdEncoding.EncodeToString([]byte(testAPIKey))
+
+	tests := []struct {
+		name   string
+		url    string
+		header string
+		cookie string
+		status int
+	}{
+		{"bearer", "/", "Bearer " + testAPIKey, "", http.StatusOK},
+		{"basic", "/", "Basic " + basic, "", http.StatusOK},
+		{"basic lower case", "/", "basic " + basic, "", http.StatusOK},
+		{"no scheme", "/", testAPIKey, "", http.StatusOK},
+		{"query", "/?apikey=" + testAPIKey, "", "", http.StatusOK},
+		{"cookie", "/", "", testAPIKey, http.StatusOK},
+		{"missing", "/", "", "", http.StatusUnauthorized},
+		{"not a key", "/", "Bearer uuid:key", "", http.StatusUnauthorized},
+		{"invalid basic", "/", "Basic not-base64", "", http.StatusUnauthorized},
+		{"basic without password", "/", "Basic " + base64.StdEncoding.EncodeToString([]byte("uuid")), "", http.StatusUnauthorized},
+		{"unsupported scheme", "/", "Digest username=uuid", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			caller, ok := cfg.authenticate(w, req, "ns")
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				require.False(t, ok)
+				require.Equal(t, []string{`Bearer realm="streamer"`, `Basic realm="streamer"`}, w.Header().Values("WWW-Authenticate"))
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, testAPIKey, caller.APIKey)
+		})
+	}
+
+	// without the query and cookie sources, only the header is read
+	req := httptest.NewRequest(http.MethodPost, "/?apikey="+testAPIKey, nil)
+	w := httptest.NewRecorder()
+	_, ok := StreamConfig{}.authenticate(w, req, "ns")
+	require.False(t, ok)
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestVaultAuthentication(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("prod: uuid:prod\n"), 0o600))
+	keys, err := vault.Open(file, "")
+	require.NoError(t, err)
+
+	cfg := StreamConfig{
+		Auth: &VaultAuthenticator{
+			Callers: vault.Callers{"web-app": {"prod", "dev"}},
+			Vault:   keys,
+		},
+		AuthCookie: "session",
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		header    string
+		cookie    string
+		status    int
+		apiKey    string
+	}{
+		{"bearer", "prod", "Bearer web-app", "", http.StatusOK, "uuid:prod"},
+		{"cookie", "prod", "", "web-app", http.StatusOK, "uuid:prod"},
+		{"missing", "prod", "", "", http.StatusUnauthorized, ""},
+		{"unknown caller", "prod", "Bearer uuid:prod", "", http.StatusUnauthorized, ""},
+		{"other namespace", "staging", "Bearer web-app", "", http.StatusForbidden, ""},
+		{"no key in the vault", "dev", "Bearer web-app", "", http.StatusForbidden, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/action/"+tt.namespace+"/hello", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "session", Value: tt.cookie})
+			}
+			w := httptest.NewRecorder()
+
+			caller, ok := cfg.authenticate(w, req, tt.namespace)
+			require.Equal(t, tt.status == http.StatusOK, ok)
+			require.Equal(t, tt.apiKey, caller.APIKey)
+			require.Nil(t, caller.Claims)
+			require.Equal(t, tt.status, w.Code)
+			require.NotContains(t, w.Body.String(), "uuid:prod")
+		})
+	}
+}
+
+// signToken makes an EdDSA token with claims.
+func signToken(t *testing.T, key ed25519.PrivateKey, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "EdDSA", "kid": "test"}) + "." + encode(claims)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(ed25519.Sign(key, []byte(signed)))
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	file := filepath.Join(t.TempDir(), "keys.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("prod: uuid:prod\n"), 0o600))
+	keys, err := vault.Open(file, "")
+	require.NoError(t, err)
+
+	auth := JWTAuthenticator{
+		Verifier: &jwt.Verifier{Keys: jwt.KeySet{"test": public}},
+		Rules:    map[string]string{"namespace": "{ns}", "scope": "stream:invoke"},
+		Forward:  []string{"sub", "email"},
+	}
+	webAuth := auth
+	auth.Vault = keys
+	cfg := StreamConfig{Auth: &auth, WebAuth: &webAuth}
+
+	claims := func(namespace string, scope string) map[string]interface{} {
+		return map[string]interface{}{
+			"sub":       "user-1",
+			"email":     "user@example.com",
+			"roles":     []string{"admin"},
+			"namespace": namespace,
+			"scope":     scope,
+			"exp":       time.Now().Add(time.Hour).Unix(),
+		}
+	}
+	valid := signToken(t, private, claims("prod", "openid stream:invoke"))
+
+	tests := []struct {
+		name      string
+		namespace string
+		token     string
+		status    int
+	}{
+		{"valid", "prod", valid, http.StatusOK},
+		{"missing", "prod", "", http.StatusUnauthorized},
+		{"not a token", "prod", "uuid:key", http.StatusUnauthorized},
+		{"expired", "prod", signToken(t, private, map[string]interface{}{"namespace": "prod", "scope": "stream:invoke", "exp": 1}), http.StatusUnauthorized},
+		{"other namespace", "staging", valid, http.StatusForbidden},
+		{"missing scope", "prod", signToken(t, private, claims("prod", "openid")), http.StatusForbidden},
+		{"no key in the vault", "dev", signToken(t, private, claims("dev", "stream:invoke")), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/action/"+tt.namespace+"/hello", nil)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			caller, ok := cfg.authenticate(w, req, tt.namespace)
+			require.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				require.False(t, ok)
+				return
+			}
+			require.True(t, ok)
+			require.Equal(t, "uuid:prod", caller.APIKey)
+			require.Equal(t, map[string]interface{}{"sub": "user-1", "email": "user@example.com"}, caller.Claims)
+		})
+	}
+
+	t.Run("web", func(t *testing.T) {
+		// the web actions need no key
+		req := httptest.NewRequest(http.MethodPost, "/web/dev/hello", nil)
+		req.Header.Set("Authorization", "Bearer "+signToken(t, private, claims("dev", "stream:invoke")))
+		caller, ok := cfg.authenticateWeb(httptest.NewRecorder(), req, "dev")
+		require.True(t, ok)
+		require.Empty(t, caller.APIKey)
+		require.Equal(t, "user-1", caller.Claims["sub"])
+
+		w := httptest.NewRecorder()
+		_, ok = cfg.authenticateWeb(w, httptest.NewRequest(http.MethodPost, "/web/dev/hello", nil), "dev")
+		require.False(t, ok)
+		require.Equal(t, http.StatusUnauthorized, w.Code)
+
+		_, ok = StreamConfig{}.authenticateWeb(httptest.NewRecorder(), req, "dev")
+		require.True(t, ok)
+	})
+}
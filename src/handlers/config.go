@@ -0,0 +1,70 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+// StreamConfig holds what the stream handlers need to invoke an action
+// and relay its output.
+type StreamConfig struct {
+	// Invoker runs the actions, OpenWhisk at APIHost when nil.
+	Invoker Invoker
+	APIHost string
+	// Auth authenticates the callers with their own credentials. When nil,
+	// the callers send the OpenWhisk key. WebAuth authenticates the callers
+	// of the web actions, which are open when it is nil. The credentials are
+	// sent in the Authorization header, or in the AuthQuery parameter or the
+	// AuthCookie cookie.
+	Auth       Authenticator
+	WebAuth    Authenticator
+	AuthQuery  string
+	AuthCookie string
+	// Limiter limits the streams of the authenticated callers, nil for no
+	// limits.
+	Limiter *limiter.Limiter
+	// StreamerAddr is the address the action sockets listen on.
+	StreamerAddr string
+	// IngestBaseURL is the URL the actions reach the streamer at, to post
+	// their output to the ingest endpoint.
+	IngestBaseURL string
+	// HeartbeatMessage is written to the client after HeartbeatInterval
+	// without output from the action, to keep the proxies in between from
+	// dropping the connection. 0 disables it.
+	HeartbeatInterval time.Duration
+	HeartbeatMessage  string
+	// IdleTimeout ends the stream when the action writes nothing for that
+	// long. 0 disables it.
+	IdleTimeout time.Duration
+	// MaxBodySize limits the JSON body of the requests, 0 for no limit.
+	MaxBodySize int64
+	// ReservedParams is what to do with the parameters of the callers named
+	// as those of the streamer: reject, the default, or namespace them.
+	ReservedParams string
+	// Recorder records the streams to replay them, nil disables it.
+	Recorder *recorder.Recorder
+	Registry *streams.Registry
+	Polls    *PollSessions
+	TCP      tcp.Options
+}
@@ -0,0 +1,146 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSPolicy sets which browser origins may call the streamer, and what they
+// may send and read.
+type CORSPolicy struct {
+	// AllowOrigins are origins, as https://app.example.com, patterns for
+	// their subdomains, as https://*.example.com, or * for any origin.
+	AllowOrigins     []string
+	AllowMethods     string
+	AllowHeaders     string
+	ExposeHeaders    string
+	AllowCredentials bool
+	// MaxAge is how long the browsers may cache the preflight responses, 0
+	// to leave it to them.
+	MaxAge time.Duration
+}
+
+// CORSRoute applies Policy to the paths starting with Path.
+type CORSRoute struct {
+	Path   string
+	Policy CORSPolicy
+}
+
+// WithCORS answers the preflight requests and adds the CORS headers to the
+// responses, following the policy of the longest route matching the path,
+// or policy.
+func WithCORS(policy CORSPolicy, routes []CORSRoute, next http.Handler) http.Handler {
+	routes = slices.Clone(routes)
+	slices.SortFunc(routes, func(a, b CORSRoute) int { return len(b.Path) - len(a.Path) })
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := policy
+		for _, route := range routes {
+			if strings.HasPrefix(r.URL.Path, route.Path) {
+				p = route.Policy
+				break
+			}
+		}
+
+		if p.echoesOrigin() {
+			w.Header().Add("Vary", "Origin")
+		}
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && p.allows(origin)
+		preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+		if allowed {
+			p.writeHeaders(w, r, origin, preflight)
+		} else if origin != "" && preflight {
+			http.Error(w, "Origin not allowed", http.StatusForbidden)
+			return
+		}
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (p CORSPolicy) writeHeaders(w http.ResponseWriter, r *http.Request, origin string, preflight bool) {
+	h := w.Header()
+	if p.echoesOrigin() {
+		h.Set("Access-Control-Allow-Origin", origin)
+	} else {
+		h.Set("Access-Control-Allow-Origin", "*")
+	}
+	if p.AllowCredentials {
+		h.Set("Access-Control-Allow-Credentials", "true")
+	}
+	if !preflight {
+		if p.ExposeHeaders != "" {
+			h.Set("Access-Control-Expose-Headers", p.ExposeHeaders)
+		}
+		return
+	}
+
+	h.Set("Access-Control-Allow-Methods", p.AllowMethods)
+	allowHeaders := p.AllowHeaders
+	if allowHeaders == "*" && p.AllowCredentials {
+		// * is taken literally with credentials
+		allowHeaders = r.Header.Get("Access-Control-Request-Headers")
+	}
+	if allowHeaders != "" {
+		h.Set("Access-Control-Allow-Headers", allowHeaders)
+	}
+	if p.MaxAge > 0 {
+		h.Set("Access-Control-Max-Age", strconv.Itoa(int(p.MaxAge.Seconds())))
+	}
+}
+
+// echoesOrigin tells whether the allowed origins are answered with their
+// own name, rather than *: with credentials, the browsers want the origin.
+func (p CORSPolicy) echoesOrigin() bool {
+	return !slices.Contains(p.AllowOrigins, "*") || p.AllowCredentials
+}
+
+func (p CORSPolicy) allows(origin string) bool {
+	for _, allowed := range p.AllowOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) || matchOriginPattern(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchOriginPattern matches origin with a pattern for subdomains, as
+// https://*.example.com, which does not match https://example.com itself.
+func matchOriginPattern(pattern string, origin string) bool {
+	scheme, domain, found := strings.Cut(pattern, "://*.")
+	if !found {
+		return false
+	}
+	prefix := scheme + "://"
+	if len(origin) <= len(prefix) || !strings.EqualFold(origin[:len(prefix)], prefix) {
+		return false
+	}
+	host := origin[len(prefix):]
+	suffix := "." + domain
+	return len(host) > len(suffix) && strings.EqualFold(host[len(host)-len(suffix):], suffix) && !strings.ContainsAny(host, "/@")
+}
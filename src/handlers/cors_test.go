@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithCORS(t *testing.T) {
+	policy := CORSPolicy{
+		AllowOrigins:     []string{"https://app.example.com", "https://*.example.org"},
+		AllowMethods:     "GET, POST, OPTIONS",
+		AllowHeaders:     "*",
+		ExposeHeaders:    "X-Stream-Status, X-Activation-Id",
+		AllowCredentials: true,
+		MaxAge:           10 * time.Minute,
+	}
+	public := CORSPolicy{AllowOrigins: []string{"*"}, AllowMethods: "GET"}
+	handler := WithCORS(policy, []CORSRoute{{Path: "/web/public/", Policy: public}}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	serve := func(method string, path string, origin string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("allowed origin", func(t *testing.T) {
+		for _, origin := range []string{"https://app.example.com", "https://eu.example.org", "https://a.b.example.org"} {
+			w := serve(http.MethodPost, "/action/ns/hello", origin)
+			require.Equal(t, http.StatusOK, w.Code)
+			require.Equal(t, origin, w.Header().Get("Access-Control-Allow-Origin"))
+			require.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			require.Equal(t, "X-Stream-Status, X-Activation-Id", w.Header().Get("Access-Control-Expose-Headers"))
+			require.Equal(t, "Origin", w.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("other origins", func(t *testing.T) {
+		for _, origin := range []string{"https://evil.com", "https://example.org", "http://eu.example.org", "https://app.example.com.evil.com", ""} {
+			w := serve(http.MethodPost, "/action/ns/hello", origin)
+			require.Equal(t, http.StatusOK, w.Code, origin)
+			require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), origin)
+			require.Equal(t, "Origin", w.Header().Get("Vary"))
+		}
+	})
+
+	t.Run("preflight", func(t *testing.T) {
+		w := serve(http.MethodOptions, "/action/ns/hello", "https://app.example.com",
+			"Access-Control-Request-Method", "POST",
+			"Access-Control-Request-Headers", "authorization, content-type")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+		require.Equal(t, "authorization, content-type", w.Header().Get("Access-Control-Allow-Headers"))
+		require.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		require.Empty(t, w.Header().Get("Access-Control-Expose-Headers"))
+
+		w = serve(http.MethodOptions, "/action/ns/hello", "https://evil.com", "Access-Control-Request-Method", "POST")
+		require.Equal(t, http.StatusForbidden, w.Code)
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("route override", func(t *testing.T) {
+		w := serve(http.MethodGet, "/web/public/hello", "https://evil.com")
+		require.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+		require.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+		require.Empty(t, w.Header().Get("Vary"))
+
+		w = serve(http.MethodOptions, "/web/public/hello", "https://evil.com", "Access-Control-Request-Method", "GET")
+		require.Equal(t, http.StatusNoContent, w.Code)
+		require.Equal(t, "GET", w.Header().Get("Access-Control-Allow-Methods"))
+		require.Empty(t, w.Header().Get("Access-Control-Max-Age"))
+	})
+}
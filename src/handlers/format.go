@@ -0,0 +1,160 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const ndjsonContentType = "application/x-ndjson"
+
+// streamFormat encodes what is written to the client of a stream.
+type streamFormat interface {
+	// contentType is empty to let it be detected.
+	contentType() string
+	data(seq int, data []byte) []byte
+	heartbeat(message string) []byte
+	end(seq int, relayed int64, activationID string, summary []byte) []byte
+	error(e streamError) []byte
+}
+
+// negotiateFormat picks the format of the route, if set, or the one asked
+// with the format query parameter, or else with the Accept header, plain
+// text by default.
+func negotiateFormat(r *http.Request) streamFormat {
+	format := r.URL.Query().Get("format")
+	if route := routeOf(r); route != nil && route.Format != "" {
+		format = route.Format
+	}
+	switch format {
+	case "ndjson":
+		return ndjsonFormat{now: time.Now}
+	case "text":
+		return textFormat{}
+	}
+	if strings.Contains(r.Header.Get("Accept"), ndjsonContentType) {
+		return ndjsonFormat{now: time.Now}
+	}
+	return textFormat{}
+}
+
+// textFormat writes the output of the action as it is, a line per message.
+type textFormat struct{}
+
+func (textFormat) contentType() string {
+	return ""
+}
+
+func (textFormat) data(seq int, data []byte) []byte {
+	return append(bytes.Clone(data), '\n')
+}
+
+func (textFormat) heartbeat(message string) []byte {
+	return []byte(message)
+}
+
+func (textFormat) end(seq int, relayed int64, activationID string, summary []byte) []byte {
+	record := endRecord
+	if summary := strings.TrimSpace(string(summary)); summary != "" {
+		record += " " + summary
+	}
+	return []byte(record + "\n")
+}
+
+func (textFormat) error(e streamError) []byte {
+	return []byte(errorRecordPrefix + e.Code + ": " + e.Message + "\n")
+}
+
+// ndjsonFormat writes a JSON object per line, safe to parse whatever the
+// action writes.
+type ndjsonFormat struct {
+	now func() time.Time
+}
+
+type ndjsonRecord struct {
+	Type         string          `json:"type"`
+	Seq          int             `json:"seq,omitempty"`
+	Timestamp    string          `json:"ts"`
+	Data         json.RawMessage `json:"data,omitempty"`
+	Encoding     string          `json:"encoding,omitempty"`
+	Bytes        *int64          `json:"bytes,omitempty"`
+	ActivationID string          `json:"activation_id,omitempty"`
+	Summary      json.RawMessage `json:"summary,omitempty"`
+	Code         string          `json:"code,omitempty"`
+	Message      string          `json:"message,omitempty"`
+}
+
+func (f ndjsonFormat) contentType() string {
+	return ndjsonContentType
+}
+
+func (f ndjsonFormat) data(seq int, data []byte) []byte {
+	value, encoding := jsonValue(data)
+	return f.encode(ndjsonRecord{Type: "data", Seq: seq, Data: value, Encoding: encoding})
+}
+
+func (f ndjsonFormat) heartbeat(message string) []byte {
+	return f.encode(ndjsonRecord{Type: "heartbeat"})
+}
+
+func (f ndjsonFormat) end(seq int, relayed int64, activationID string, summary []byte) []byte {
+	record := ndjsonRecord{Type: "end", Seq: seq, Bytes: &relayed, ActivationID: activationID}
+	if len(bytes.TrimSpace(summary)) > 0 {
+		record.Summary, record.Encoding = jsonValue(summary)
+	}
+	return f.encode(record)
+}
+
+func (f ndjsonFormat) error(e streamError) []byte {
+	return f.encode(ndjsonRecord{Type: "error", Code: e.Code, Message: e.Message})
+}
+
+func (f ndjsonFormat) encode(record ndjsonRecord) []byte {
+	record.Timestamp = f.now().UTC().Format(time.RFC3339Nano)
+	line, err := json.Marshal(record)
+	if err != nil {
+		log.Println("Error encoding NDJSON record:", err)
+		return nil
+	}
+	return append(line, '\n')
+}
+
+// jsonValue is data itself when it is valid JSON, a string when it is text,
+// or a base64 string, with the base64 encoding, when it is binary.
+func jsonValue(data []byte) (json.RawMessage, string) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && json.Valid(trimmed) {
+		// compacted, since the record must fit on a line
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, trimmed); err == nil {
+			return compact.Bytes(), ""
+		}
+	}
+	if utf8.Valid(data) {
+		value, _ := json.Marshal(string(data))
+		return value, ""
+	}
+	value, _ := json.Marshal(base64.StdEncoding.EncodeToString(data))
+	return value, "base64"
+}
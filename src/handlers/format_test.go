@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		accept   string
+		expected streamFormat
+	}{
+		{"default", "/action/ns/hello", "", textFormat{}},
+		{"query", "/action/ns/hello?format=ndjson", "", ndjsonFormat{}},
+		{"accept", "/action/ns/hello", "application/x-ndjson", ndjsonFormat{}},
+		{"query wins", "/action/ns/hello?format=text", "application/x-ndjson", textFormat{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			req.Header.Set("Accept", tt.accept)
+			require.IsType(t, tt.expected, negotiateFormat(req))
+		})
+	}
+}
+
+func TestNDJSONFormat(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	f := ndjsonFormat{now: func() time.Time { return now }}
+
+	tests := []struct {
+		name     string
+		record   []byte
+		expected string
+	}{
+		{
+			name:     "text",
+			record:   f.data(1, []byte("hello \"world\"\n")),
+			expected: `{"type":"data","seq":1,"ts":"2025-01-01T12:00:00Z","data":"hello \"world\"\n"}`,
+		},
+		{
+			name:     "json",
+			record:   f.data(2, []byte("{\"token\": \"hi\",\n \"n\": 1}\n")),
+			expected: `{"type":"data","seq":2,"ts":"2025-01-01T12:00:00Z","data":{"token":"hi","n":1}}`,
+		},
+		{
+			name:     "binary",
+			record:   f.data(3, []byte{0xff, 0x00, 0x01}),
+			expected: `{"type":"data","seq":3,"ts":"2025-01-01T12:00:00Z","data":"/wAB","encoding":"base64"}`,
+		},
+		{
+			name:     "heartbeat",
+			record:   f.heartbeat("\n"),
+			expected: `{"type":"heartbeat","ts":"2025-01-01T12:00:00Z"}`,
+		},
+		{
+			name:     "end",
+			record:   f.end(4, 0, "abc", nil),
+			expected: `{"type":"end","seq":4,"ts":"2025-01-01T12:00:00Z","bytes":0,"activation_id":"abc"}`,
+		},
+		{
+			name:     "end with summary",
+			record:   f.end(4, 12, "", []byte(`{"tokens": 3}`)),
+			expected: `{"type":"end","seq":4,"ts":"2025-01-01T12:00:00Z","bytes":12,"summary":{"tokens":3}}`,
+		},
+		{
+			name:     "error",
+			record:   f.error(streamError{Code: "timeout", Message: "no output"}),
+			expected: `{"type":"error","ts":"2025-01-01T12:00:00Z","code":"timeout","message":"no output"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expected+"\n", string(tt.record))
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/streamerpb"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// GRPCServer serves the Streamer gRPC API, invoking the actions and relaying
+// their output like ActionStreamHandler.
+type GRPCServer struct {
+	streamerpb.UnimplementedStreamerServer
+
+	limiter *limiter.Limiter
+	config  atomic.Pointer[StreamConfig]
+}
+
+func NewGRPCServer(cfg StreamConfig, l *limiter.Limiter) *GRPCServer {
+	s := &GRPCServer{limiter: l}
+	s.SetConfig(cfg)
+	return s
+}
+
+// SetConfig applies cfg to the calls started afterwards.
+func (s *GRPCServer) SetConfig(cfg StreamConfig) {
+	s.config.Store(&cfg)
+}
+
+func (s *GRPCServer) InvokeStream(req *streamerpb.InvokeStreamRequest, srv grpc.ServerStreamingServer[streamerpb.StreamEvent]) error {
+	cfg := *s.config.Load()
+
+	if req.Namespace == "" || req.Action == "" {
+		return status.Error(codes.InvalidArgument, "namespace and action are required")
+	}
+	namespace, actionToInvoke := req.Namespace, req.Action
+	if req.Package != "" {
+		actionToInvoke = req.Package + "/" + req.Action
+	}
+	log.Printf("gRPC Action request: %s (%s)", actionToInvoke, namespace)
+
+	apiKey := req.Auth
+	if apiKey == "" {
+		apiKey = metadataAuthToken(srv.Context())
+	}
+	if apiKey == "" {
+		return status.Error(codes.Unauthenticated, "Missing auth or authorization metadata")
+	}
+	caller := Caller{APIKey: apiKey}
+	if cfg.Auth == nil && !validAPIKey(apiKey) {
+		return status.Error(codes.Unauthenticated, "Invalid API key, expected uuid:key")
+	}
+	if cfg.Auth != nil {
+		var err error
+		caller, err = cfg.Auth.Authenticate(srv.Context(), apiKey, namespace)
+		switch {
+		case errors.Is(err, ErrUnauthenticated):
+			return status.Error(codes.Unauthenticated, err.Error())
+		case errors.Is(err, ErrForbidden):
+			return status.Error(codes.PermissionDenied, err.Error())
+		case err != nil:
+			log.Printf("Authentication for namespace %s failed: %s", namespace, err)
+			return status.Error(codes.Internal, "Authentication failed")
+		}
+	}
+
+	if s.limiter != nil {
+		release, err := s.limiter.Acquire(namespace, apiKey)
+		if err != nil {
+			log.Printf("Rejected request for namespace %s: %s", namespace, err.Error())
+			return status.Error(codes.ResourceExhausted, err.Error())
+		}
+		defer release()
+	}
+
+	ctx, done := context.WithCancel(srv.Context())
+	defer done()
+
+	sock, err := tcp.SetupTcpServer(ctx, cfg.StreamerAddr, cfg.TCP)
+	if err != nil {
+		if errors.Is(err, tcp.ErrNoFreePort) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	stream := streams.NewStream("grpc", namespace, actionToInvoke, peerIP(ctx), sock, done)
+	cfg.Registry.Add(stream)
+	defer cfg.Registry.Remove(stream.ID)
+
+	params, err := cfg.checkReserved(req.Parameters.AsMap())
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	streamParams := streamParams(cfg, stream, sock)
+	for key, value := range streamParams {
+		params[key] = value
+	}
+	addClaims(params, caller)
+
+	results, err := cfg.invoker().Invoke(ctx, InvokeRequest{
+		Kind:         "action",
+		Namespace:    namespace,
+		Action:       actionToInvoke,
+		APIKey:       caller.APIKey,
+		Params:       params,
+		StreamParams: streamParams,
+	})
+	if err != nil {
+		return status.Error(codes.Internal, err.Error())
+	}
+
+	out := &grpcOutput{srv: srv, stream: stream}
+	relayStream(ctx, out, cfg, stream, sock, results)
+	return out.err
+}
+
+// metadataAuthToken is the credential in the authorization metadata, as in
+// the Authorization header.
+func metadataAuthToken(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return ""
+	}
+	token, _ := parseAuthorization(values[0])
+	return token
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// grpcOutput sends the stream as events. Errors end the call with a status,
+// after the chunks already sent.
+type grpcOutput struct {
+	srv    grpc.ServerStreamingServer[streamerpb.StreamEvent]
+	stream *streams.Stream
+	err    error
+}
+
+func (o *grpcOutput) data(seq int, data []byte) error {
+	return o.srv.Send(&streamerpb.StreamEvent{Event: &streamerpb.StreamEvent_Chunk{
+		Chunk: &streamerpb.Chunk{Seq: int64(seq), Data: data},
+	}})
+}
+
+func (o *grpcOutput) heartbeat() error {
+	return o.srv.Send(&streamerpb.StreamEvent{Event: &streamerpb.StreamEvent_Heartbeat{
+		Heartbeat: &streamerpb.Heartbeat{},
+	}})
+}
+
+func (o *grpcOutput) end(seq int, relayed int64, activationID string, summary []byte) error {
+	return o.srv.Send(&streamerpb.StreamEvent{Event: &streamerpb.StreamEvent_Result{
+		Result: &streamerpb.Result{
+			StreamId:     o.stream.ID,
+			ActivationId: activationID,
+			Bytes:        relayed,
+			Summary:      summary,
+		},
+	}})
+}
+
+func (o *grpcOutput) fail(e streamError) {
+	o.err = status.Error(grpcCode(e.status), e.Code+": "+e.Message)
+}
+
+// grpcCode is the gRPC equivalent of the HTTP status of a stream error.
+func grpcCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusGatewayTimeout:
+		return codes.DeadlineExceeded
+	case http.StatusBadGateway, http.StatusServiceUnavailable:
+		return codes.Unavailable
+	default:
+		return codes.Internal
+	}
+}
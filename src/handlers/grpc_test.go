@@ -0,0 +1,129 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/openserverless-streaming-proxy/streamerpb"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestGRPCInvokeStream(t *testing.T) {
+	// the fake controller starts the action, which streams its parameters
+	invoked := make(chan map[string]interface{}, 1)
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1/namespaces/ns/actions/pkg/hello", r.URL.Path)
+		params := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		invoked <- params
+
+		go func() {
+			host, port := params["STREAM_HOST"].(string), params["STREAM_PORT"].(string)
+			marker := params["STREAM_END_MARKER"].(string)
+			_ = sendTcpSocketMsg(host, port, "hello "+params["name"].(string)+marker)
+		}()
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte(`{"activationId": "abc123"}`))
+	}))
+	defer controller.Close()
+
+	registry := streams.NewRegistry()
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	streamerpb.RegisterStreamerServer(server, NewGRPCServer(StreamConfig{
+		APIHost:      controller.URL,
+		StreamerAddr: "localhost",
+		Registry:     registry,
+	}, nil))
+	go server.Serve(listener)
+	defer server.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	defer conn.Close()
+	client := streamerpb.NewStreamerClient(conn)
+
+	t.Run("stream", func(t *testing.T) {
+		parameters, err := structpb.NewStruct(map[string]interface{}{"name": "grpc"})
+		require.NoError(t, err)
+		ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+testAPIKey)
+		call, err := client.InvokeStream(ctx, &streamerpb.InvokeStreamRequest{
+			Namespace:  "ns",
+			Package:    "pkg",
+			Action:     "hello",
+			Parameters: parameters,
+		})
+		require.NoError(t, err)
+
+		event, err := call.Recv()
+		require.NoError(t, err)
+		require.Equal(t, int64(1), event.GetChunk().GetSeq())
+		require.Equal(t, "hello grpc", string(event.GetChunk().GetData()))
+
+		event, err = call.Recv()
+		require.NoError(t, err)
+		result := event.GetResult()
+		require.NotNil(t, result)
+		require.Equal(t, "abc123", result.ActivationId)
+		require.Equal(t, int64(10), result.Bytes)
+
+		_, err = call.Recv()
+		require.Equal(t, io.EOF, err)
+
+		params := <-invoked
+		require.Equal(t, "grpc", params["name"])
+		require.NotContains(t, params, "STREAM_INGEST_URL")
+		require.Empty(t, registry.List())
+	})
+
+	t.Run("invalid requests", func(t *testing.T) {
+		call, err := client.InvokeStream(context.Background(), &streamerpb.InvokeStreamRequest{Namespace: "ns"})
+		require.NoError(t, err)
+		_, err = call.Recv()
+		require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		call, err = client.InvokeStream(context.Background(), &streamerpb.InvokeStreamRequest{Namespace: "ns", Action: "hello"})
+		require.NoError(t, err)
+		_, err = call.Recv()
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		call, err = client.InvokeStream(context.Background(), &streamerpb.InvokeStreamRequest{Namespace: "ns", Action: "hello", Auth: "uuid:key"})
+		require.NoError(t, err)
+		_, err = call.Recv()
+		require.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
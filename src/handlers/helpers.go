@@ -18,31 +18,105 @@
 package handlers
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"regexp"
 	"strings"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
 )
 
-func injectHostPortInBody(r *http.Request, tcpServerHost string, tcpServerPort string) (map[string]interface{}, error) {
+// socketError answers a request whose action socket could not be opened.
+// Running out of ports is temporary, so the client is told to retry.
+func socketError(w http.ResponseWriter, err error) {
+	if errors.Is(err, tcp.ErrNoFreePort) {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
+
+// reservedPrefix starts the names of the parameters the streamer gives to
+// the actions, which the callers can not set.
+const reservedPrefix = "STREAM_"
+
+// injectStreamParams decodes the JSON body of the request, up to
+// MaxBodySize, adding the default parameters of the route, if any, and the
+// parameters the action needs to stream back to the streamer. The body is
+// validated against the schema of the route, if any.
+func (cfg StreamConfig) injectStreamParams(r *http.Request, params map[string]string) (map[string]interface{}, error) {
 	body := r.Body
 	defer body.Close()
+	if cfg.MaxBodySize > 0 {
+		body = http.MaxBytesReader(nil, body, cfg.MaxBodySize)
+	}
 
 	jsonBody := make(map[string]interface{})
-	err := json.NewDecoder(body).Decode(&jsonBody)
+	decoder := json.NewDecoder(body)
+	err := decoder.Decode(&jsonBody)
 	// In case of a GET request, the body is empty and we have to initialize it manually
 	if err == io.EOF {
 		jsonBody = make(map[string]interface{})
 	} else if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("Invalid JSON body: %w", err)
+	} else if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("Invalid JSON body: more than one JSON object")
 	}
 
-	jsonBody["STREAM_HOST"] = tcpServerHost
-	jsonBody["STREAM_PORT"] = tcpServerPort
+	jsonBody, err = cfg.checkReserved(jsonBody)
+	if err != nil {
+		return nil, err
+	}
+	if route := routeOf(r); route != nil {
+		for key, value := range route.Params {
+			if _, ok := jsonBody[key]; !ok {
+				jsonBody[key] = value
+			}
+		}
+		if route.Schema != nil {
+			if err := route.Schema.Validate(jsonBody); err != nil {
+				return nil, fmt.Errorf("Invalid parameters:\n%w", err)
+			}
+		}
+	}
+	for key, value := range params {
+		jsonBody[key] = value
+	}
 	return jsonBody, nil
 }
 
+// checkReserved rejects the parameters of the caller with a reserved name
+// or, when ReservedParams is namespace, renames them with a CLIENT_ prefix.
+func (cfg StreamConfig) checkReserved(params map[string]interface{}) (map[string]interface{}, error) {
+	for key, value := range params {
+		if !strings.HasPrefix(key, reservedPrefix) {
+			continue
+		}
+		if cfg.ReservedParams != "namespace" {
+			return nil, fmt.Errorf("Parameter %s is reserved, the names starting with %s are set by the streamer", key, reservedPrefix)
+		}
+		delete(params, key)
+		params["CLIENT_"+key] = value
+	}
+	return params, nil
+}
+
+// paramsError answers a request whose parameters could not be read.
+func paramsError(w http.ResponseWriter, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusBadRequest)
+}
+
 func getNamespaceAndAction(r *http.Request) (string, string) {
 	namespace := r.PathValue("ns")
 	pkg := r.PathValue("pkg")
@@ -56,15 +130,42 @@ func getNamespaceAndAction(r *http.Request) (string, string) {
 	return namespace, actionToInvoke
 }
 
+// extractAuthToken returns the credential in the Authorization header.
 func extractAuthToken(r *http.Request) (string, error) {
-	apiKey := r.Header.Get("Authorization")
-	if apiKey == "" {
+	auth := r.Header.Get("Authorization")
+	if auth == "" {
 		return "", errors.New("Missing Authorization header")
 	}
+	return parseAuthorization(auth)
+}
 
-	// get the apikey without the Bearer prefix
-	apiKey = strings.TrimPrefix(apiKey, "Bearer ")
-	return apiKey, nil
+// parseAuthorization returns the token of Bearer credentials, the
+// user:password of Basic ones, as wsk sends the API key, or the value as it
+// is without a scheme.
+func parseAuthorization(auth string) (string, error) {
+	scheme, credentials, found := strings.Cut(auth, " ")
+	if !found {
+		return auth, nil
+	}
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		return strings.TrimSpace(credentials), nil
+	case "basic":
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(credentials))
+		if err != nil || !strings.Contains(string(decoded), ":") {
+			return "", errors.New("Invalid Basic credentials")
+		}
+		return string(decoded), nil
+	default:
+		return "", fmt.Errorf("Unsupported authorization scheme %s", scheme)
+	}
+}
+
+// apiKeyPattern is the shape of the OpenWhisk API keys, uuid:key.
+var apiKeyPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}:[0-9A-Za-z]+$`)
+
+func validAPIKey(apiKey string) bool {
+	return apiKeyPattern.MatchString(apiKey)
 }
 
 func ensureProtocolScheme(url string) string {
@@ -89,3 +190,17 @@ func ensurePackagePresent(actionToInvoke string) string {
 	}
 	return actionToInvoke
 }
+
+// clientIP returns the address of the HTTP client, preferring the first
+// X-Forwarded-For entry when the streamer is behind a proxy.
+func clientIP(r *http.Request) string {
+	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		return strings.TrimSpace(first)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
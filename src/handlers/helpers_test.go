@@ -20,12 +20,16 @@ package handlers
 import (
 	"bytes"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 )
 
-func TestInjectHostPortInBody(t *testing.T) {
+// testAPIKey has the shape of the OpenWhisk API keys.
+const testAPIKey = "23bc46b1-71f6-4ed5-8c54-816aa4f8c502:123zO3xZCLrMN6v2BKK1dXYFpXlPkccOFqm12CdAsMgRU4VrNZ9lyGVCGuMDGIwP"
+
+func TestInjectStreamParams(t *testing.T) {
 	tests := []struct {
 		name           string
 		body           string
@@ -69,7 +73,8 @@ func TestInjectHostPortInBody(t *testing.T) {
 			req, err := http.NewRequest("POST", "/", bytes.NewBufferString(tt.body))
 			require.NoError(t, err)
 
-			actualBody, err := injectHostPortInBody(req, tt.tcpServerHost, tt.tcpServerPort)
+			params := map[string]string{"STREAM_HOST": tt.tcpServerHost, "STREAM_PORT": tt.tcpServerPort}
+			actualBody, err := StreamConfig{}.injectStreamParams(req, params)
 			if tt.expectedErrMsg != "" {
 				require.Error(t, err)
 				require.Contains(t, err.Error(), tt.expectedErrMsg)
@@ -84,6 +89,42 @@ func TestInjectHostPortInBody(t *testing.T) {
 	}
 }
 
+func TestRequestParamsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      StreamConfig
+		body     string
+		status   int
+		expected map[string]interface{}
+	}{
+		{"malformed", StreamConfig{}, `{"key": `, http.StatusBadRequest, nil},
+		{"not an object", StreamConfig{}, `["key"]`, http.StatusBadRequest, nil},
+		{"trailing data", StreamConfig{}, `{"key": "value"} {}`, http.StatusBadRequest, nil},
+		{"too large", StreamConfig{MaxBodySize: 16}, `{"key": "a long enough value"}`, http.StatusRequestEntityTooLarge, nil},
+		{"under the limit", StreamConfig{MaxBodySize: 16}, `{"key": "value"}`, http.StatusOK, map[string]interface{}{"key": "value", "STREAM_HOST": "localhost"}},
+		{"reserved", StreamConfig{}, `{"STREAM_HOST": "evil.com"}`, http.StatusBadRequest, nil},
+		{
+			"namespaced", StreamConfig{ReservedParams: "namespace"}, `{"STREAM_HOST": "evil.com"}`, http.StatusOK,
+			map[string]interface{}{"CLIENT_STREAM_HOST": "evil.com", "STREAM_HOST": "localhost"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/", bytes.NewBufferString(tt.body))
+			body, err := tt.cfg.injectStreamParams(req, map[string]string{"STREAM_HOST": "localhost"})
+			if tt.status == http.StatusOK {
+				require.NoError(t, err)
+				require.Equal(t, tt.expected, body)
+				return
+			}
+			w := httptest.NewRecorder()
+			paramsError(w, err)
+			require.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
 func TestGetNamespaceAndAction(t *testing.T) {
 	tests := []struct {
 		name           string
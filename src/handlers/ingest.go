@@ -0,0 +1,88 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+// IngestHandler relays the body of the request as the output of the action
+// of the stream, for the actions that can not open a TCP connection. The
+// stream ends with the request carrying end=true.
+func IngestHandler(registry *streams.Registry) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stream, ok := registry.Get(r.PathValue("id"))
+		if !ok {
+			http.Error(w, "Stream not found", http.StatusNotFound)
+			return
+		}
+
+		token, err := extractAuthToken(r)
+		if err != nil || !stream.ValidIngestToken(token) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="streamer-ingest"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		end := false
+		if value := r.URL.Query().Get("end"); value != "" {
+			end, err = strconv.ParseBool(value)
+			if err != nil {
+				http.Error(w, "end must be a boolean", http.StatusBadRequest)
+				return
+			}
+		}
+
+		err = stream.Ingest(r.Body, end)
+		if errors.Is(err, tcp.ErrStreamFinished) {
+			http.Error(w, err.Error(), http.StatusGone)
+			return
+		}
+		if err != nil {
+			log.Printf("Stream %s: error reading ingest request: %s", stream.ID, err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// ingestURL is where the action of the stream can post its output.
+func ingestURL(baseURL string, streamID string) string {
+	return strings.TrimSuffix(baseURL, "/") + "/ingest/" + streamID
+}
+
+// streamParams are the parameters the action needs to stream its output,
+// either to the socket or to the ingest endpoint. The ingest endpoint is
+// only given when its URL is configured: the host the client asked for is
+// not to be trusted with the ingest token.
+func streamParams(cfg StreamConfig, stream *streams.Stream, sock *tcp.SocketsServer) map[string]string {
+	params := sock.Params()
+	if cfg.IngestBaseURL != "" {
+		params["STREAM_INGEST_URL"] = ingestURL(cfg.IngestBaseURL, stream.ID)
+		params["STREAM_INGEST_TOKEN"] = stream.IngestToken
+	}
+	return params
+}
@@ -0,0 +1,96 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIngestHandler(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+
+	registry := streams.NewRegistry()
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	registry.Add(stream)
+
+	router := http.NewServeMux()
+	router.HandleFunc("POST /ingest/{id}", IngestHandler(registry))
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	// without a configured URL, the ingest endpoint is not given out
+	params := streamParams(StreamConfig{}, stream, sock)
+	require.NotContains(t, params, "STREAM_INGEST_URL")
+	require.NotContains(t, params, "STREAM_INGEST_TOKEN")
+	require.Equal(t, sock.Port, params["STREAM_PORT"])
+
+	params = streamParams(StreamConfig{IngestBaseURL: "https://streamer.example.com/"}, stream, sock)
+	require.Equal(t, "https://streamer.example.com/ingest/"+stream.ID, params["STREAM_INGEST_URL"])
+	require.Equal(t, stream.IngestToken, params["STREAM_INGEST_TOKEN"])
+
+	post := func(path string, token string, body string) int {
+		req, err := http.NewRequest("POST", server.URL+path, strings.NewReader(body))
+		require.NoError(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	received := make(chan string)
+	go func() {
+		defer close(received)
+		for data := range sock.StreamDataChan {
+			received <- string(data)
+		}
+	}()
+
+	require.Equal(t, http.StatusNotFound, post("/ingest/unknown", stream.IngestToken, "hello"))
+	require.Equal(t, http.StatusUnauthorized, post("/ingest/"+stream.ID, "", "hello"))
+	require.Equal(t, http.StatusUnauthorized, post("/ingest/"+stream.ID, "wrong", "hello"))
+	require.Equal(t, http.StatusBadRequest, post("/ingest/"+stream.ID+"?end=maybe", stream.IngestToken, ""))
+
+	require.Equal(t, http.StatusNoContent, post("/ingest/"+stream.ID, stream.IngestToken, "hello"))
+	require.Equal(t, "hello", <-received)
+	require.Equal(t, http.StatusNoContent, post("/ingest/"+stream.ID+"?end=true", stream.IngestToken, "world"))
+	require.Equal(t, "world", <-received)
+
+	select {
+	case _, open := <-received:
+		require.False(t, open)
+	case <-time.After(time.Second):
+		require.Fail(t, "Timeout waiting for the stream to end")
+	}
+	require.Equal(t, http.StatusGone, post("/ingest/"+stream.ID, stream.IngestToken, "late"))
+}
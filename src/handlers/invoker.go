@@ -0,0 +1,63 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"net/http"
+)
+
+// InvokeRequest is an action to start, streaming its output to the streamer.
+type InvokeRequest struct {
+	// Kind is action or web, the route the action was requested on.
+	Kind      string
+	Namespace string
+	// Action is the name of the action, with its package if any.
+	Action string
+	// APIKey is the credential of the client, empty for web actions.
+	APIKey string
+	// Params are the parameters of the action, StreamParams included.
+	Params map[string]interface{}
+	// StreamParams are the parameters the action needs to stream.
+	StreamParams map[string]string
+	// Header is the header of the client request, nil without one.
+	Header http.Header
+}
+
+// Invocation is the outcome of invoking an action.
+type Invocation struct {
+	ActivationID string
+	Err          error
+}
+
+// Invoker runs the actions the streamer relays the output of.
+type Invoker interface {
+	// Invoke starts the action, returning an error when it could not. The
+	// outcome of the invocation is sent on the returned channel, possibly
+	// only once the action is done, or never. The channel is buffered and
+	// never closed, not to block or panic the sender after the stream ends.
+	Invoke(ctx context.Context, req InvokeRequest) (<-chan Invocation, error)
+}
+
+// invoker is the Invoker of cfg, OpenWhisk at APIHost when not set.
+func (cfg StreamConfig) invoker() Invoker {
+	if cfg.Invoker != nil {
+		return cfg.Invoker
+	}
+	return &OpenWhiskInvoker{APIHost: cfg.APIHost}
+}
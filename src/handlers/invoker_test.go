@@ -0,0 +1,113 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// invocationResult waits for the outcome of an invocation.
+func invocationResult(t *testing.T, results <-chan Invocation) Invocation {
+	select {
+	case res := <-results:
+		return res
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "Timeout waiting for the invocation")
+		return Invocation{}
+	}
+}
+
+func TestWebhookInvoker(t *testing.T) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/ns/pkg/hello", r.URL.Path)
+		require.Equal(t, "web", r.Header.Get("X-Stream-Kind"))
+		require.Equal(t, "ns", r.Header.Get("X-Stream-Namespace"))
+		require.Equal(t, "pkg/hello", r.Header.Get("X-Stream-Action"))
+		params := map[string]interface{}{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&params))
+		require.Equal(t, "1234", params["STREAM_PORT"])
+		w.Header().Set("X-Activation-Id", "run-1")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	invoker := &WebhookInvoker{URL: server.URL + "/{namespace}/{action}"}
+	req := InvokeRequest{
+		Kind:      "web",
+		Namespace: "ns",
+		Action:    "pkg/hello",
+		Params:    map[string]interface{}{"STREAM_PORT": "1234"},
+	}
+
+	results, err := invoker.Invoke(context.Background(), req)
+	require.NoError(t, err)
+	res := invocationResult(t, results)
+	require.NoError(t, res.Err)
+	require.Equal(t, "run-1", res.ActivationID)
+
+	status = http.StatusBadGateway
+	results, err = invoker.Invoke(context.Background(), req)
+	require.NoError(t, err)
+	require.EqualError(t, invocationResult(t, results).Err, "not ok (502 Bad Gateway)")
+}
+
+func TestProcessInvoker(t *testing.T) {
+	dir := t.TempDir()
+	req := InvokeRequest{
+		Namespace:    "ns",
+		Action:       "hello",
+		Params:       map[string]interface{}{"name": "world", "STREAM_PORT": "1234"},
+		StreamParams: map[string]string{"STREAM_PORT": "1234"},
+	}
+
+	// the stream parameters are in the environment, all of them on stdin,
+	// but not the environment of the streamer
+	t.Setenv("ADMIN_TOKEN", "secret")
+	invoker := &ProcessInvoker{
+		Command: []string{"sh", "-c", `test "$STREAM_PORT$STREAM_ACTION$ADMIN_TOKEN" = 1234hello && cat > params.json`},
+		Dir:     dir,
+	}
+	results, err := invoker.Invoke(context.Background(), req)
+	require.NoError(t, err)
+	res := invocationResult(t, results)
+	require.NoError(t, res.Err)
+	require.NotEmpty(t, res.ActivationID)
+
+	data, err := os.ReadFile(filepath.Join(dir, "params.json"))
+	require.NoError(t, err)
+	require.JSONEq(t, `{"name": "world", "STREAM_PORT": "1234"}`, string(data))
+
+	invoker = &ProcessInvoker{Command: []string{"sh", "-c", "exit 3"}}
+	results, err = invoker.Invoke(context.Background(), req)
+	require.NoError(t, err)
+	require.EqualError(t, invocationResult(t, results).Err, "exit status 3")
+
+	invoker = &ProcessInvoker{Command: []string{filepath.Join(dir, "missing")}}
+	_, err = invoker.Invoke(context.Background(), req)
+	require.ErrorContains(t, err, "Error running the action")
+}
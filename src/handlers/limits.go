@@ -0,0 +1,85 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+)
+
+// acquireStream holds a stream slot for the caller, once authenticated,
+// rejecting with 429 the requests exceeding the global, namespace or API key
+// limits. The API key limits apply to the identity of the caller, so that
+// the unauthenticated requests take nothing from the others. The slot is
+// held until release is called, or until the stream ends when a poll
+// session takes it over from the returned request.
+func (cfg StreamConfig) acquireStream(w http.ResponseWriter, r *http.Request, namespace string, caller Caller) (*http.Request, func(), bool) {
+	if cfg.Limiter == nil {
+		return r, func() {}, true
+	}
+	release, err := cfg.Limiter.Acquire(namespace, caller.Identity)
+	if err != nil {
+		var limitErr *limiter.LimitError
+		if errors.As(err, &limitErr) {
+			retryAfter := int(math.Ceil(limitErr.RetryAfter.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(max(1, retryAfter)))
+		}
+		log.Printf("Rejected request for namespace %s: %s", namespace, err.Error())
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return nil, nil, false
+	}
+	slot := &streamSlot{release: release}
+	r = r.WithContext(context.WithValue(r.Context(), slotKey{}, slot))
+	return r, func() {
+		if !slot.taken {
+			release()
+		}
+	}, true
+}
+
+// streamSlot is the stream slot held by a request.
+type streamSlot struct {
+	release func()
+	taken   bool
+}
+
+type slotKey struct{}
+
+// takeStreamSlot takes over the stream slot of r, for a stream outliving the
+// request, returning the function to release it once the stream ends.
+func takeStreamSlot(r *http.Request) func() {
+	slot, ok := r.Context().Value(slotKey{}).(*streamSlot)
+	if !ok {
+		return func() {}
+	}
+	slot.taken = true
+	return slot.release
+}
+
+// LimitsUsageHandler reports the current usage of the stream limits as JSON.
+func LimitsUsageHandler(l *limiter.Limiter) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, l.Usage())
+	}
+}
@@ -0,0 +1,98 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireStream(t *testing.T) {
+	cfg := StreamConfig{Limiter: limiter.New(limiter.Config{
+		Namespace: limiter.Limit{Rate: 0.5, Burst: 1},
+		APIKey:    limiter.Limit{MaxStreams: 1},
+	})}
+	caller := Caller{Identity: "uuid:key"}
+	req := httptest.NewRequest("POST", "/action/ns/action", nil)
+
+	_, release, ok := cfg.acquireStream(httptest.NewRecorder(), req, "ns", caller)
+	require.True(t, ok)
+
+	// while the first stream is open the same key is over its cap
+	rec := httptest.NewRecorder()
+	_, _, ok = cfg.acquireStream(rec, req, "other", caller)
+	require.False(t, ok)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "1", rec.Header().Get("Retry-After"))
+
+	release()
+	rec = httptest.NewRecorder()
+	_, _, ok = cfg.acquireStream(rec, req, "ns", caller)
+	require.False(t, ok)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+	require.Equal(t, "2", rec.Header().Get("Retry-After"))
+	require.Contains(t, rec.Body.String(), "namespace limit exceeded")
+}
+
+func TestStreamLimitsAfterAuthentication(t *testing.T) {
+	cfg := StreamConfig{AuthQuery: "key", Limiter: limiter.New(limiter.Config{
+		Global: limiter.Limit{Rate: 0.5, Burst: 1},
+	})}
+
+	// the requests failing authentication take nothing from the limits
+	for range 3 {
+		rec := httptest.NewRecorder()
+		ActionStreamHandler(cfg)(rec, httptest.NewRequest("POST", "/action/ns/action", nil))
+		require.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+
+	// the credentials in the query are limited as the others
+	apiKey := "23bc46b1-71f6-4ed5-8c54-816aa4f8c502:key"
+	req := httptest.NewRequest("POST", "/action/ns/action?key="+apiKey, nil)
+	caller, ok := cfg.authenticate(httptest.NewRecorder(), req, "ns")
+	require.True(t, ok)
+	require.Equal(t, apiKey, caller.Identity)
+	_, release, ok := cfg.acquireStream(httptest.NewRecorder(), req, "ns", caller)
+	require.True(t, ok)
+	release()
+}
+
+func TestStreamSlotTakenOver(t *testing.T) {
+	cfg := StreamConfig{Limiter: limiter.New(limiter.Config{Global: limiter.Limit{MaxStreams: 1}})}
+	req := httptest.NewRequest("POST", "/action/ns/action?mode=poll", nil)
+
+	// a poll session keeps the slot once the request is over
+	r, release, ok := cfg.acquireStream(httptest.NewRecorder(), req, "ns", Caller{})
+	require.True(t, ok)
+	pollRelease := takeStreamSlot(r)
+	release()
+
+	rec := httptest.NewRecorder()
+	_, _, ok = cfg.acquireStream(rec, req, "ns", Caller{})
+	require.False(t, ok)
+	require.Equal(t, http.StatusTooManyRequests, rec.Code)
+
+	pollRelease()
+	_, release, ok = cfg.acquireStream(httptest.NewRecorder(), req, "ns", Caller{})
+	require.True(t, ok)
+	release()
+}
@@ -18,7 +18,13 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
 
 	"github.com/apache/openwhisk-client-go/whisk"
 )
@@ -37,3 +43,122 @@ func NewOpenWhiskClient(apiHost string, apiKey string, namespace string) *whisk.
 
 	return client
 }
+
+// OpenWhiskInvoker invokes OpenWhisk actions, and web actions, on APIHost.
+type OpenWhiskInvoker struct {
+	APIHost string
+}
+
+func (o *OpenWhiskInvoker) Invoke(ctx context.Context, req InvokeRequest) (<-chan Invocation, error) {
+	if req.Kind == "web" {
+		return o.invokeWebAction(req)
+	}
+
+	activationID, err := invokeAction(o.APIHost, req.APIKey, req.Namespace, req.Action, req.Params)
+	if err != nil {
+		return nil, err
+	}
+	// the invocation is not blocking, its outcome is known already
+	results := make(chan Invocation, 1)
+	results <- Invocation{ActivationID: activationID}
+	return results, nil
+}
+
+// invokeAction invokes the action without waiting for it to complete, so
+// only its activation id, when known, is returned.
+func invokeAction(apiHost string, apiKey string, namespace string, action string, params map[string]interface{}) (string, error) {
+	client := NewOpenWhiskClient(apiHost, apiKey, namespace)
+
+	result, httpResp, err := client.Actions.Invoke(action, params, false, false)
+	if err != nil {
+		return "", err
+	}
+
+	// We need to handle status in the range from 200 to 299
+	// as success, and everything else as an error.
+	// In particular, we need to handle 202 Accepted
+	// as a success, because the action is invoked
+	// asynchronously and the response is not available yet.
+	// We also need to handle 204 No Content as a success,
+	// because the action is invoked and there is no response.
+	// It seems that the invoker is releasing a 202 Accepted
+	// after 60 seconds, so we need to handle that as well.
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		return "", errors.New("Error invoking action: " + httpResp.Status)
+	}
+
+	if activation, ok := result.(map[string]interface{}); ok {
+		if activationID, ok := activation["activationId"].(string); ok {
+			return activationID, nil
+		}
+	}
+	return "", nil
+}
+
+// invokeWebAction invokes the web action in the background, as it returns
+// only once the action is done.
+func (o *OpenWhiskInvoker) invokeWebAction(req InvokeRequest) (<-chan Invocation, error) {
+	jsonData, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, errors.New("Error encoding JSON body: " + err.Error())
+	}
+	url := fmt.Sprintf("%s/api/v1/web/%s/%s", o.APIHost, req.Namespace, ensurePackagePresent(req.Action))
+
+	// Read headers and set them in the request
+	headers := make(map[string]string)
+	for key, values := range req.Header {
+		// Use the first value for the header
+		if len(values) > 0 {
+			headers[key] = values[0]
+		}
+	}
+
+	results := make(chan Invocation, 1)
+	go asyncPostWebAction(results, url, jsonData, headers)
+	return results, nil
+}
+
+// asyncPostWebAction invokes the web action, sending the outcome to results
+// when it returns.
+func asyncPostWebAction(results chan<- Invocation, url string, body []byte, headers map[string]string) {
+	bodyReader := strings.NewReader(string(body))
+
+	req, err := http.NewRequest("POST", ensureProtocolScheme(url), bodyReader)
+	if err != nil {
+		results <- Invocation{Err: err}
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Length", fmt.Sprintf("%d", bodyReader.Len()))
+	req.ContentLength = int64(bodyReader.Len())
+
+	// Aggiungi le intestazioni opzionali
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	client := &http.Client{}
+	httpResp, err := client.Do(req)
+	if err != nil {
+		results <- Invocation{Err: err}
+		return
+	}
+	httpResp.Body.Close()
+	result := Invocation{ActivationID: httpResp.Header.Get("X-Openwhisk-Activation-Id")}
+
+	// We need to handle status in the range from 200 to 299
+	// as success, and everything else as an error.
+	// In particular, we need to handle 202 Accepted
+	// as a success, because the action is invoked
+	// asynchronously and the response is not available yet.
+	// We also need to handle 204 No Content as a success,
+	// because the action is invoked and there is no response.
+	// It seems that the invoker is releasing a 202 Accepted
+	// after 60 seconds, so we need to handle that as well.
+	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
+		result.Err = fmt.Errorf("not ok (%s)", httpResp.Status)
+	} else {
+		log.Printf("Received status code %d: %s", httpResp.StatusCode, httpResp.Status)
+	}
+	results <- result
+}
@@ -0,0 +1,313 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+// pollExpiry ends the streams, and forgets the finished ones, that are not
+// polled for this long.
+var pollExpiry = time.Minute
+
+const (
+	defaultPollWait = 30 * time.Second
+	maxPollWait     = 60 * time.Second
+)
+
+// pollMode tells whether the client asked to poll for the output, instead of
+// receiving it as a streaming response.
+func pollMode(r *http.Request) bool {
+	return r.URL.Query().Get("mode") == "poll"
+}
+
+// streamContext is the parent of the stream context: the request, unless
+// the stream outlives it to be polled.
+func streamContext(r *http.Request) context.Context {
+	if pollMode(r) {
+		return context.WithoutCancel(r.Context())
+	}
+	return r.Context()
+}
+
+// PollSessions holds the output of the streams started with mode=poll, until
+// their clients fetch it.
+type PollSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*pollSession
+}
+
+func NewPollSessions() *PollSessions {
+	return &PollSessions{sessions: make(map[string]*pollSession)}
+}
+
+func (p *PollSessions) add(id string, s *pollSession) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions[id] = s
+}
+
+func (p *PollSessions) get(id string) (*pollSession, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	s, ok := p.sessions[id]
+	return s, ok
+}
+
+func (p *PollSessions) remove(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sessions, id)
+}
+
+// startPolling relays the output of the action to a new poll session in the
+// background, answering the client with the id to poll and the token to
+// poll it with. The session holds the stream slot of the request until the
+// stream ends.
+func startPolling(w http.ResponseWriter, r *http.Request, cfg StreamConfig, stream *streams.Stream, sock *tcp.SocketsServer, results <-chan Invocation, done context.CancelFunc) {
+	release := takeStreamSlot(r)
+	session := &pollSession{
+		token:   newPollToken(),
+		header:  make(http.Header),
+		status:  http.StatusOK,
+		updated: make(chan struct{}),
+	}
+	session.expiry = time.AfterFunc(pollExpiry, func() {
+		if !session.isFinished() {
+			log.Printf("Stream %s not polled for %s, terminating it", stream.ID, pollExpiry)
+			stream.Terminate(fmt.Sprintf("not polled for %s", pollExpiry))
+		}
+		cfg.Polls.remove(stream.ID)
+	})
+
+	cfg.Polls.add(stream.ID, session)
+
+	go func() {
+		// the session is the client now, the request is over
+		relay(session, r.WithContext(context.WithoutCancel(r.Context())), cfg, stream, sock, results)
+		done()
+		release()
+		cfg.Registry.Remove(stream.ID)
+		session.finish()
+	}()
+
+	pollURL := "/poll/" + stream.ID
+	w.Header().Set("Location", pollURL)
+	writeJSON(w, http.StatusAccepted, map[string]string{"id": stream.ID, "poll_url": pollURL, "token": session.token})
+}
+
+func newPollToken() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// pollSession is the http.ResponseWriter relay writes to in poll mode. Each
+// write is a chunk, numbered from 0, the cursor of the clients. The
+// heartbeats are not chunks, they only answer the pending polls.
+type pollSession struct {
+	// token authenticates the polls, only the client that started the
+	// stream has it
+	token    string
+	mu       sync.Mutex
+	header   http.Header
+	status   int
+	chunks   [][]byte
+	offset   int
+	beats    int
+	finished bool
+	updated  chan struct{}
+	expiry   *time.Timer
+}
+
+func (s *pollSession) Header() http.Header {
+	return s.header
+}
+
+func (s *pollSession) WriteHeader(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *pollSession) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chunks = append(s.chunks, bytes.Clone(p))
+	s.notify()
+	return len(p), nil
+}
+
+func (s *pollSession) heartbeat() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.beats++
+	s.notify()
+}
+
+// Flush does nothing, the chunks are ready as soon as they are written.
+func (s *pollSession) Flush() {}
+
+func (s *pollSession) finish() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.finished = true
+	s.notify()
+}
+
+func (s *pollSession) isFinished() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.finished
+}
+
+// notify wakes up the pending polls, with s.mu held.
+func (s *pollSession) notify() {
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+type pollResponse struct {
+	ID           string   `json:"id"`
+	Cursor       int      `json:"cursor"`
+	Chunks       []string `json:"chunks"`
+	Done         bool     `json:"done"`
+	Heartbeat    bool     `json:"heartbeat,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	HTTPStatus   int      `json:"http_status,omitempty"`
+	ActivationID string   `json:"activation_id,omitempty"`
+}
+
+// poll returns the chunks from cursor on, waiting up to wait for some if
+// there are none yet, or for a heartbeat. The chunks before cursor are
+// dropped, the client has them already.
+func (s *pollSession) poll(ctx context.Context, cursor int, wait time.Duration) (pollResponse, int) {
+	s.expiry.Reset(pollExpiry)
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cursor < s.offset {
+		return pollResponse{}, http.StatusGone
+	}
+	if cursor > s.offset+len(s.chunks) {
+		return pollResponse{}, http.StatusBadRequest
+	}
+	s.chunks = s.chunks[cursor-s.offset:]
+	s.offset = cursor
+
+	beats := s.beats
+	for len(s.chunks) == 0 && !s.finished && s.beats == beats {
+		updated := s.updated
+		s.mu.Unlock()
+		select {
+		case <-updated:
+			s.mu.Lock()
+			continue
+		case <-timeout.C:
+		case <-ctx.Done():
+		}
+		s.mu.Lock()
+		break
+	}
+
+	resp := pollResponse{
+		Cursor: s.offset + len(s.chunks),
+		Chunks: make([]string, len(s.chunks)),
+		Done:   s.finished,
+		// a heartbeat with no output yet
+		Heartbeat: len(s.chunks) == 0 && !s.finished && s.beats != beats,
+	}
+	for i, chunk := range s.chunks {
+		resp.Chunks[i] = string(chunk)
+	}
+	if s.finished {
+		resp.Status = s.header.Get("X-Stream-Status")
+		resp.HTTPStatus = s.status
+		resp.ActivationID = s.header.Get("X-Activation-Id")
+	}
+	return resp, http.StatusOK
+}
+
+// PollHandler returns the output of a stream started with mode=poll, from
+// the cursor query parameter on, waiting up to wait seconds for new output.
+// The polls carry the token of the session as bearer token.
+func PollHandler(polls *PollSessions) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		session, ok := polls.get(id)
+		if !ok {
+			http.Error(w, "Stream not found", http.StatusNotFound)
+			return
+		}
+		token, err := extractAuthToken(r)
+		if err != nil || subtle.ConstantTimeCompare([]byte(token), []byte(session.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="streamer-poll"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		cursor, err := queryInt(r, "cursor", 0)
+		if err != nil || cursor < 0 {
+			http.Error(w, "cursor must be a non negative number", http.StatusBadRequest)
+			return
+		}
+		wait := defaultPollWait
+		if seconds, err := queryInt(r, "wait", -1); err != nil {
+			http.Error(w, "wait must be a number of seconds", http.StatusBadRequest)
+			return
+		} else if seconds >= 0 {
+			wait = min(time.Duration(seconds)*time.Second, maxPollWait)
+		}
+
+		resp, status := session.poll(r.Context(), cursor, wait)
+		switch status {
+		case http.StatusGone:
+			http.Error(w, "The output before the cursor was already fetched", status)
+			return
+		case http.StatusBadRequest:
+			http.Error(w, "cursor is past the output", status)
+			return
+		}
+		resp.ID = id
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func queryInt(r *http.Request, name string, fallback int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return fallback, nil
+	}
+	return strconv.Atoi(value)
+}
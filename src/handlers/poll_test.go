@@ -0,0 +1,201 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolling(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+
+	cfg := StreamConfig{Registry: streams.NewRegistry(), Polls: NewPollSessions()}
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	cfg.Registry.Add(stream)
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /poll/{id}", PollHandler(cfg.Polls))
+
+	req := httptest.NewRequest("POST", "/action/ns/hello?mode=poll", nil)
+	require.True(t, pollMode(req))
+	w := httptest.NewRecorder()
+	startPolling(w, req, cfg, stream, sock, nil, cancel)
+
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, "/poll/"+stream.ID, w.Header().Get("Location"))
+	var started map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+	require.Equal(t, stream.ID, started["id"])
+	require.NotEmpty(t, started["token"])
+
+	pollWithToken := func(query string, token string) (int, pollResponse) {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/poll/"+stream.ID+query, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		var resp pollResponse
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		}
+		return w.Code, resp
+	}
+	poll := func(query string) (int, pollResponse) {
+		return pollWithToken(query, started["token"])
+	}
+
+	// only the client that started the stream can poll it
+	code, _ := pollWithToken("?cursor=0&wait=0", "")
+	require.Equal(t, http.StatusUnauthorized, code)
+	code, _ = pollWithToken("?cursor=0&wait=0", stream.IngestToken)
+	require.Equal(t, http.StatusUnauthorized, code)
+
+	code, resp := poll("?cursor=0&wait=0")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Chunks)
+	require.False(t, resp.Done)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	code, resp = poll("?cursor=0&wait=5")
+	require.Equal(t, http.StatusOK, code)
+	require.Equal(t, []string{"hello\n"}, resp.Chunks)
+	require.Equal(t, 1, resp.Cursor)
+
+	// polling from a cursor drops the chunks before it
+	code, resp = poll("?cursor=1&wait=0")
+	require.Equal(t, http.StatusOK, code)
+	require.Empty(t, resp.Chunks)
+	code, _ = poll("?cursor=0")
+	require.Equal(t, http.StatusGone, code)
+	code, _ = poll("?cursor=5")
+	require.Equal(t, http.StatusBadRequest, code)
+	code, _ = poll("?cursor=-1")
+	require.Equal(t, http.StatusBadRequest, code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/poll/unknown", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+
+	_, err = conn.Write([]byte(sock.EndMarker))
+	require.NoError(t, err)
+	conn.Close()
+
+	var chunks []string
+	cursor := resp.Cursor
+	for !resp.Done {
+		code, resp = poll("?wait=5&cursor=" + strconv.Itoa(cursor))
+		require.Equal(t, http.StatusOK, code)
+		chunks = append(chunks, resp.Chunks...)
+		cursor = resp.Cursor
+	}
+	require.Equal(t, []string{endRecord + "\n"}, chunks)
+	require.Equal(t, statusComplete, resp.Status)
+	require.Equal(t, http.StatusOK, resp.HTTPStatus)
+
+	_, ok := cfg.Registry.Get(stream.ID)
+	require.False(t, ok)
+}
+
+func TestPollExpiry(t *testing.T) {
+	defer func(expiry time.Duration) { pollExpiry = expiry }(pollExpiry)
+	pollExpiry = 50 * time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+	cfg := StreamConfig{Registry: streams.NewRegistry(), Polls: NewPollSessions()}
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	cfg.Registry.Add(stream)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	startPolling(httptest.NewRecorder(), httptest.NewRequest("POST", "/action/ns/hello?mode=poll", nil), cfg, stream, sock, nil, cancel)
+	session, ok := cfg.Polls.get(stream.ID)
+	require.True(t, ok)
+
+	// the stream not polled is terminated, not complete
+	require.Eventually(t, session.isFinished, time.Second, 10*time.Millisecond)
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	require.Equal(t, "[stream error] terminated: stream not polled for 50ms\n", string(session.chunks[len(session.chunks)-1]))
+	require.Equal(t, "terminated", session.header.Get("X-Stream-Status"))
+}
+
+func TestPollHeartbeats(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+	cfg := StreamConfig{
+		Registry:          streams.NewRegistry(),
+		Polls:             NewPollSessions(),
+		HeartbeatInterval: 20 * time.Millisecond,
+		HeartbeatMessage:  ": keepalive\n\n",
+	}
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	cfg.Registry.Add(stream)
+
+	startPolling(httptest.NewRecorder(), httptest.NewRequest("POST", "/action/ns/hello?mode=poll", nil), cfg, stream, sock, nil, cancel)
+	session, ok := cfg.Polls.get(stream.ID)
+	require.True(t, ok)
+
+	// a heartbeat answers the pending poll, with no chunk
+	resp, code := session.poll(context.Background(), 0, 5*time.Second)
+	require.Equal(t, http.StatusOK, code)
+	require.True(t, resp.Heartbeat)
+	require.Empty(t, resp.Chunks)
+	require.Equal(t, 0, resp.Cursor)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	time.Sleep(100 * time.Millisecond)
+
+	// the heartbeats in between are not stored
+	resp, code = session.poll(context.Background(), 0, 0)
+	require.Equal(t, http.StatusOK, code)
+	require.False(t, resp.Heartbeat)
+	require.Equal(t, []string{"hello\n"}, resp.Chunks)
+	require.Equal(t, 1, resp.Cursor)
+}
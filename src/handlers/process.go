@@ -0,0 +1,71 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// ProcessInvoker runs the actions as local commands. The parameters to
+// stream are in the environment of the command, with STREAM_NAMESPACE and
+// STREAM_ACTION, and all the parameters are written to its standard input
+// as JSON. The command is killed when the stream ends. Of the environment of
+// the streamer, holding its credentials, the command only gets PATH.
+type ProcessInvoker struct {
+	Command []string
+	Dir     string
+}
+
+func (p *ProcessInvoker) Invoke(ctx context.Context, req InvokeRequest) (<-chan Invocation, error) {
+	if len(p.Command) == 0 {
+		return nil, errors.New("no command to run the action")
+	}
+	params, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding JSON body: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, p.Command[0], p.Command[1:]...)
+	cmd.Dir = p.Dir
+	cmd.Env = []string{"PATH=" + os.Getenv("PATH"), "STREAM_NAMESPACE=" + req.Namespace, "STREAM_ACTION=" + req.Action}
+	for key, value := range req.StreamParams {
+		cmd.Env = append(cmd.Env, key+"="+value)
+	}
+	cmd.Stdin = bytes.NewReader(params)
+	cmd.Stdout = log.Writer()
+	cmd.Stderr = log.Writer()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("Error running the action: %w", err)
+	}
+	pid := strconv.Itoa(cmd.Process.Pid)
+	log.Printf("Action %s started, pid %s", req.Action, pid)
+
+	results := make(chan Invocation, 1)
+	go func() {
+		results <- Invocation{ActivationID: pid, Err: cmd.Wait()}
+	}()
+	return results, nil
+}
@@ -0,0 +1,302 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+// invocationGrace is how long to wait, once the output of the action is
+// over, for its invocation to return, to report its outcome.
+const invocationGrace = time.Second
+
+// streamError is a failure that ends a stream. Before anything is written
+// it is answered with status, afterwards it can only be reported in the body.
+type streamError struct {
+	Code    string
+	Message string
+	status  int
+}
+
+// The last line of a stream is an end record when the action is done, or
+// an error record when the stream broke. A stream ending with neither was
+// dropped.
+const (
+	endRecord         = "[stream end]"
+	errorRecordPrefix = "[stream error] "
+)
+
+// statusComplete is the X-Stream-Status trailer of a stream that ended
+// cleanly, otherwise it is the code of the error, or client_closed.
+const statusComplete = "complete"
+
+// streamOutput is where relayStream writes the output of the action, over
+// HTTP or gRPC.
+type streamOutput interface {
+	data(seq int, data []byte) error
+	heartbeat() error
+	end(seq int, relayed int64, activationID string, summary []byte) error
+	fail(e streamError)
+}
+
+// relay writes the output of the action to the client until the action is
+// done, the client goes away, the action is idle for too long or results,
+// which may be nil, reports an error invoking it. The outcome is reported in
+// the trailers.
+func relay(w http.ResponseWriter, r *http.Request, cfg StreamConfig, stream *streams.Stream, sock *tcp.SocketsServer, results <-chan Invocation) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	format := negotiateFormat(r)
+	if contentType := format.contentType(); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	w.Header().Set("Trailer", "X-Stream-Status, X-Stream-Bytes, X-Activation-Id")
+	if cfg.Recorder != nil {
+		// the id to replay the stream with
+		w.Header().Set("X-Stream-Id", stream.ID)
+	}
+
+	out := &httpOutput{w: w, flusher: flusher, format: format, heartbeatMessage: cfg.HeartbeatMessage}
+	status := relayStream(r.Context(), out, cfg, stream, sock, results)
+
+	w.Header().Set("X-Stream-Status", status)
+	w.Header().Set("X-Stream-Bytes", strconv.FormatInt(stream.BytesRelayed(), 10))
+	w.Header().Set("X-Activation-Id", stream.ActivationID())
+}
+
+// relayStream writes the output of the action to out, until the stream
+// ends or ctx is done, and returns the status of the stream.
+func relayStream(ctx context.Context, out streamOutput, cfg StreamConfig, stream *streams.Stream, sock *tcp.SocketsServer, results <-chan Invocation) string {
+	heartbeat := newOptionalTimer(cfg.HeartbeatInterval)
+	defer stopOptionalTimer(heartbeat)
+	idle := newOptionalTimer(cfg.IdleTimeout)
+	defer stopOptionalTimer(idle)
+	if rec := startRecording(cfg, stream); rec != nil {
+		defer rec.Close()
+		out = recordingOutput{streamOutput: out, rec: rec}
+	}
+
+	seq := 0
+	fail := func(e streamError) string {
+		log.Printf("Stream %s failed (%s): %s", stream.ID, e.Code, e.Message)
+		out.fail(e)
+		return e.Code
+	}
+	// invoked returns the status of the stream when the invocation failed
+	invoked := func(res Invocation) (string, bool) {
+		results = nil
+		if res.ActivationID != "" {
+			stream.SetActivationID(res.ActivationID)
+		}
+		if res.Err != nil {
+			return fail(streamError{"action_error", "error invoking the action: " + res.Err.Error(), http.StatusBadGateway}), false
+		}
+		return "", true
+	}
+
+	for {
+		select {
+		case data, isChannelOpen := <-sock.StreamDataChan:
+			if !isChannelOpen {
+				// the socket closed as the stream was cut short
+				if reason, terminated := stream.Terminated(); terminated {
+					return fail(streamError{"terminated", "stream " + reason, http.StatusServiceUnavailable})
+				}
+				summary, ended := sock.Summary()
+				if err := sock.Err(); err != nil && !ended {
+					return fail(streamError{"action_connection", "connection with the action broken: " + err.Error(), http.StatusBadGateway})
+				}
+				// the action is done writing, its invocation should return
+				// shortly, telling whether it succeeded
+				if results != nil {
+					select {
+					case res := <-results:
+						if status, ok := invoked(res); !ok {
+							return status
+						}
+					case <-time.After(invocationGrace):
+					case <-ctx.Done():
+						return "client_closed"
+					}
+				}
+				_ = out.end(seq+1, stream.BytesRelayed(), stream.ActivationID(), summary)
+				return statusComplete
+			}
+			seq++
+			if err := out.data(seq, data); err != nil {
+				// the client is gone, there is no one to tell
+				log.Printf("Stream %s: failed to write data: %s", stream.ID, err.Error())
+				return "client_closed"
+			}
+			stream.AddBytes(len(data))
+			resetOptionalTimer(heartbeat, cfg.HeartbeatInterval)
+			resetOptionalTimer(idle, cfg.IdleTimeout)
+
+		case <-timerC(heartbeat):
+			if err := out.heartbeat(); err != nil {
+				log.Println("Error writing heartbeat:", err)
+				return "client_closed"
+			}
+			heartbeat.Reset(cfg.HeartbeatInterval)
+
+		case <-timerC(idle):
+			return fail(streamError{"timeout", fmt.Sprintf("no output from the action for %s", cfg.IdleTimeout), http.StatusGatewayTimeout})
+
+		case <-ctx.Done():
+			log.Println("Client closed connection")
+			return "client_closed"
+
+		case res := <-results:
+			if status, ok := invoked(res); !ok {
+				return status
+			}
+		}
+	}
+}
+
+// httpOutput writes the stream in format to an HTTP response.
+type httpOutput struct {
+	w                http.ResponseWriter
+	flusher          http.Flusher
+	format           streamFormat
+	heartbeatMessage string
+	// until something is written, errors can still be given a status code
+	written bool
+}
+
+func (o *httpOutput) write(p []byte) error {
+	if _, err := o.w.Write(p); err != nil {
+		return err
+	}
+	o.written = true
+	o.flusher.Flush()
+	return nil
+}
+
+func (o *httpOutput) data(seq int, data []byte) error {
+	return o.write(o.format.data(seq, data))
+}
+
+func (o *httpOutput) heartbeat() error {
+	if h, ok := o.w.(heartbeatWriter); ok {
+		h.heartbeat()
+		return nil
+	}
+	return o.write(o.format.heartbeat(o.heartbeatMessage))
+}
+
+// heartbeatWriter is a client keeping the heartbeats out of the output, as
+// the poll sessions do.
+type heartbeatWriter interface {
+	heartbeat()
+}
+
+func (o *httpOutput) end(seq int, relayed int64, activationID string, summary []byte) error {
+	return o.write(o.format.end(seq, relayed, activationID, summary))
+}
+
+func (o *httpOutput) fail(e streamError) {
+	if !o.written {
+		http.Error(o.w, e.Message, e.status)
+		return
+	}
+	_ = o.write(o.format.error(e))
+}
+
+// startRecording starts recording stream, when enabled, returning nil when
+// it is not recorded.
+func startRecording(cfg StreamConfig, stream *streams.Stream) *recorder.Recording {
+	if cfg.Recorder == nil {
+		return nil
+	}
+	rec, err := cfg.Recorder.Start(recorder.Metadata{
+		ID:        stream.ID,
+		Kind:      stream.Kind,
+		Namespace: stream.Namespace,
+		Action:    stream.Action,
+		ClientIP:  stream.ClientIP,
+		StartTime: stream.StartTime,
+	})
+	if err != nil {
+		log.Printf("Stream %s not recorded: %s", stream.ID, err)
+		return nil
+	}
+	return rec
+}
+
+// recordingOutput records what is written to the client of a stream.
+type recordingOutput struct {
+	streamOutput
+	rec *recorder.Recording
+}
+
+func (o recordingOutput) data(seq int, data []byte) error {
+	o.rec.Data(seq, data)
+	return o.streamOutput.data(seq, data)
+}
+
+func (o recordingOutput) end(seq int, relayed int64, activationID string, summary []byte) error {
+	o.rec.End(seq, relayed, activationID, summary)
+	return o.streamOutput.end(seq, relayed, activationID, summary)
+}
+
+func (o recordingOutput) fail(e streamError) {
+	o.rec.Error(e.Code, e.Message, e.status)
+	o.streamOutput.fail(e)
+}
+
+// newOptionalTimer returns a timer firing after d, or nil when d is 0.
+func newOptionalTimer(d time.Duration) *time.Timer {
+	if d <= 0 {
+		return nil
+	}
+	return time.NewTimer(d)
+}
+
+func resetOptionalTimer(t *time.Timer, d time.Duration) {
+	if t != nil {
+		t.Reset(d)
+	}
+}
+
+func stopOptionalTimer(t *time.Timer) {
+	if t != nil {
+		t.Stop()
+	}
+}
+
+// timerC is the channel of t, nil (never ready) when t is nil.
+func timerC(t *time.Timer) <-chan time.Time {
+	if t == nil {
+		return nil
+	}
+	return t.C
+}
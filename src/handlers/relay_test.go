@@ -0,0 +1,156 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayErrors(t *testing.T) {
+	tests := []struct {
+		name           string
+		send           string
+		reset          bool
+		actionErr      error
+		heartbeat      time.Duration
+		expectedCode   int
+		expectedPrefix string
+		expectedSuffix string
+	}{
+		{
+			name:           "idle without output",
+			expectedCode:   http.StatusGatewayTimeout,
+			expectedPrefix: "no output from the action for 100ms\n",
+		},
+		{
+			name:           "idle after output",
+			send:           "hello",
+			heartbeat:      60 * time.Millisecond,
+			expectedCode:   http.StatusOK,
+			expectedPrefix: "hello\n: keepalive\n\n",
+			expectedSuffix: "[stream error] timeout: no output from the action for 100ms\n",
+		},
+		{
+			name:           "connection reset",
+			send:           "hello",
+			reset:          true,
+			expectedCode:   http.StatusOK,
+			expectedPrefix: "hello\n[stream error] action_connection: connection with the action broken: ",
+		},
+		{
+			name:           "action error",
+			actionErr:      errors.New("not ok (502 Bad Gateway)"),
+			expectedCode:   http.StatusBadGateway,
+			expectedPrefix: "error invoking the action: not ok (502 Bad Gateway)\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+			require.NoError(t, err)
+			stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+
+			if tt.send != "" {
+				// the connection stays open, as with a stalled action
+				conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+				require.NoError(t, err)
+				defer conn.Close()
+				_, err = conn.Write([]byte(tt.send))
+				require.NoError(t, err)
+
+				if tt.reset {
+					go func() {
+						time.Sleep(20 * time.Millisecond)
+						conn.(*net.TCPConn).SetLinger(0)
+						conn.Close()
+					}()
+				}
+			}
+
+			results := make(chan Invocation, 1)
+			if tt.actionErr != nil {
+				results <- Invocation{ActivationID: "abc", Err: tt.actionErr}
+			}
+
+			cfg := StreamConfig{
+				HeartbeatInterval: tt.heartbeat,
+				HeartbeatMessage:  ": keepalive\n\n",
+				IdleTimeout:       100 * time.Millisecond,
+			}
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "/action/ns/hello", nil)
+			relay(rec, req, cfg, stream, sock, results)
+
+			body := rec.Body.String()
+			require.Equal(t, tt.expectedCode, rec.Code)
+			require.True(t, strings.HasPrefix(body, tt.expectedPrefix), body)
+			require.True(t, strings.HasSuffix(body, tt.expectedSuffix), body)
+		})
+	}
+}
+
+func TestRelayTerminated(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+	registry := streams.NewRegistry()
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+	registry.Add(stream)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	relayed := make(chan struct{})
+	go func() {
+		defer close(relayed)
+		relay(rec, httptest.NewRequest("GET", "/action/ns/hello", nil), StreamConfig{}, stream, sock, make(chan Invocation, 1))
+	}()
+	require.Eventually(t, func() bool { return stream.BytesRelayed() == 5 }, time.Second, 10*time.Millisecond)
+
+	// the admin ending the stream is not the action being done
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("DELETE", "/admin/streams/"+stream.ID, nil)
+	req.SetPathValue("id", stream.ID)
+	TerminateStreamHandler(registry)(w, req)
+	require.Equal(t, http.StatusNoContent, w.Code)
+	<-relayed
+
+	require.Equal(t, "hello\n[stream error] terminated: stream terminated by the admin\n", rec.Body.String())
+	require.Equal(t, "terminated", rec.Result().Trailer.Get("X-Stream-Status"))
+}
@@ -0,0 +1,132 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+)
+
+// ReplayHandler writes a recorded stream again, in the format negotiated as
+// for the live streams. The speed query parameter divides the time between
+// the messages, 0 writes them at once. It is served on the admin listener,
+// as the recordings hold the output of every namespace.
+func ReplayHandler(rec *recorder.Recorder) func(http.ResponseWriter, *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.PathValue("id")
+		records, err := rec.Load(id)
+		if errors.Is(err, recorder.ErrNotFound) {
+			http.Error(w, "Recording not found", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Printf("Error loading recording %s: %s", id, err)
+			http.Error(w, "Error reading the recording", http.StatusInternalServerError)
+			return
+		}
+
+		speed := 1.0
+		if value := r.URL.Query().Get("speed"); value != "" {
+			speed, err = strconv.ParseFloat(value, 64)
+			if err != nil || !(speed >= 0) {
+				http.Error(w, "Invalid speed, expected a number, 0 or more", http.StatusBadRequest)
+				return
+			}
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+			return
+		}
+		format := negotiateFormat(r)
+		if contentType := format.contentType(); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Header().Set("Trailer", "X-Stream-Status, X-Stream-Bytes, X-Activation-Id")
+
+		start := records[0]
+		log.Printf("Replaying stream %s of %s (%s) at speed %g", id, start.Action, start.Namespace, speed)
+		out := &httpOutput{w: w, flusher: flusher, format: format}
+		status, relayed, activationID := replayStream(r.Context(), out, records[1:], speed)
+
+		w.Header().Set("X-Stream-Status", status)
+		w.Header().Set("X-Stream-Bytes", strconv.FormatInt(relayed, 10))
+		w.Header().Set("X-Activation-Id", activationID)
+	}
+}
+
+// replayStream writes the records to out, each at its time in the recording
+// divided by speed, and returns the status of the recorded stream, what it
+// relayed and its activation. A recording ending with neither an end nor an
+// error record is of a stream whose client went away.
+func replayStream(ctx context.Context, out streamOutput, records []recorder.Record, speed float64) (string, int64, string) {
+	start := time.Now()
+	var relayed int64
+	for _, record := range records {
+		if speed > 0 {
+			at := start.Add(time.Duration(float64(record.ElapsedTime()) / speed))
+			if !waitUntil(ctx, at) {
+				return "client_closed", relayed, ""
+			}
+		}
+
+		payload, err := record.Payload()
+		if err != nil {
+			out.fail(streamError{"replay_error", "invalid recording: " + err.Error(), http.StatusInternalServerError})
+			return "replay_error", relayed, ""
+		}
+		switch record.Type {
+		case "data":
+			if err := out.data(record.Seq, payload); err != nil {
+				return "client_closed", relayed, ""
+			}
+			relayed += int64(len(payload))
+		case "end":
+			_ = out.end(record.Seq, relayed, record.ActivationID, payload)
+			return statusComplete, relayed, record.ActivationID
+		case "error":
+			status := record.Status
+			if status == 0 {
+				status = http.StatusBadGateway
+			}
+			out.fail(streamError{record.Code, record.Message, status})
+			return record.Code, relayed, ""
+		}
+	}
+	return "client_closed", relayed, ""
+}
+
+// waitUntil waits for the time at, telling whether the client is still
+// there.
+func waitUntil(ctx context.Context, at time.Time) bool {
+	wait := time.NewTimer(time.Until(at))
+	defer wait.Stop()
+	select {
+	case <-wait.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,121 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+// recordStream relays an action writing two messages, 100ms apart, with
+// the recorder of cfg.
+func recordStream(t *testing.T, cfg StreamConfig) *streams.Stream {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+	stream := streams.NewStream("action", "ns", "default/hello", "127.0.0.1", sock, cancel)
+
+	go func() {
+		conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+		if err != nil {
+			return
+		}
+		conn.Write([]byte("hello"))
+		time.Sleep(100 * time.Millisecond)
+		conn.Write([]byte("world"))
+		conn.Close()
+	}()
+
+	rec := httptest.NewRecorder()
+	relay(rec, httptest.NewRequest(http.MethodGet, "/action/ns/hello", nil), cfg, stream, sock, nil)
+	require.Equal(t, "hello\nworld\n[stream end]\n", rec.Body.String())
+	require.Equal(t, stream.ID, rec.Header().Get("X-Stream-Id"))
+	return stream
+}
+
+func TestReplay(t *testing.T) {
+	rc := recorder.New()
+	require.NoError(t, rc.SetOptions(recorder.Options{Dir: t.TempDir()}))
+	cfg := StreamConfig{Recorder: rc}
+	stream := recordStream(t, cfg)
+
+	records, err := rc.Load(stream.ID)
+	require.NoError(t, err)
+	require.Equal(t, "ns", records[0].Namespace)
+	require.Equal(t, "end", records[len(records)-1].Type)
+
+	router := http.NewServeMux()
+	router.HandleFunc("GET /admin/replay/{id}", ReplayHandler(rc))
+	replay := func(path string, accept string) (*httptest.ResponseRecorder, time.Duration) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Accept", accept)
+		rec := httptest.NewRecorder()
+		start := time.Now()
+		router.ServeHTTP(rec, req)
+		return rec, time.Since(start)
+	}
+
+	// with the original timing
+	rec, elapsed := replay("/admin/replay/"+stream.ID, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "hello\nworld\n[stream end]\n", rec.Body.String())
+	require.Equal(t, "complete", rec.Header().Get("X-Stream-Status"))
+	require.Equal(t, "10", rec.Header().Get("X-Stream-Bytes"))
+	require.GreaterOrEqual(t, elapsed, 90*time.Millisecond)
+
+	// at once, in NDJSON
+	rec, elapsed = replay("/admin/replay/"+stream.ID+"?speed=0", ndjsonContentType)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Less(t, elapsed, 90*time.Millisecond)
+	lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Contains(t, lines[0], `"data":"hello"`)
+	require.Contains(t, lines[2], `"type":"end"`)
+
+	rec, _ = replay("/admin/replay/"+stream.ID+"?speed=-1", "")
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+	rec, _ = replay("/admin/replay/0123abcd", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestReplayError(t *testing.T) {
+	rc := recorder.New()
+	require.NoError(t, rc.SetOptions(recorder.Options{Dir: t.TempDir()}))
+	rec, err := rc.Start(recorder.Metadata{ID: "abc", Kind: "web", Namespace: "ns", Action: "hello", StartTime: time.Now()})
+	require.NoError(t, err)
+	rec.Error("timeout", "no output from the action for 1s", http.StatusGatewayTimeout)
+	require.NoError(t, rec.Close())
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/replay/abc", nil)
+	req.SetPathValue("id", "abc")
+	ReplayHandler(rc)(w, req)
+	require.Equal(t, http.StatusGatewayTimeout, w.Code)
+	require.Equal(t, "no output from the action for 1s\n", w.Body.String())
+}
@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/apache/openserverless-streaming-proxy/schema"
+)
+
+// Route maps a friendly path to an action, so that the clients do not
+// depend on its namespace and name.
+type Route struct {
+	Namespace string
+	// Action is the name of the action, with its package if any.
+	Action string
+	// Format is the output format, whatever the client asks, when set.
+	Format string
+	// Params are the default parameters of the action, which the client
+	// can override.
+	Params map[string]interface{}
+	// Schema validates the parameters, when set.
+	Schema *schema.Schema
+}
+
+type routeKey struct{}
+
+// RouteHandler serves the requests to the path of route with next, a
+// stream handler, as if they were for the action of the route.
+func RouteHandler(route Route, next http.HandlerFunc) http.HandlerFunc {
+	pkg, action, found := strings.Cut(route.Action, "/")
+	if !found {
+		pkg, action = "", route.Action
+	}
+	route.Params = jsonValues(route.Params)
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.WithContext(context.WithValue(r.Context(), routeKey{}, &route))
+		r.SetPathValue("ns", route.Namespace)
+		r.SetPathValue("pkg", pkg)
+		r.SetPathValue("action", action)
+		next(w, r)
+	}
+}
+
+// jsonValues returns params as decoded by encoding/json, for the schemas to
+// check the values read from the YAML configuration.
+func jsonValues(params map[string]interface{}) map[string]interface{} {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return params
+	}
+	values := make(map[string]interface{})
+	if err := json.Unmarshal(data, &values); err != nil {
+		return params
+	}
+	return values
+}
+
+// routeOf is the route the request was made to, nil for the /action and
+// /web paths.
+func routeOf(r *http.Request) *Route {
+	route, _ := r.Context().Value(routeKey{}).(*Route)
+	return route
+}
@@ -0,0 +1,90 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/apache/openserverless-streaming-proxy/schema"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouteHandler(t *testing.T) {
+	route := Route{
+		Namespace: "prod",
+		Action:    "llm/chat",
+		Format:    "ndjson",
+		Params:    map[string]interface{}{"model": "small", "temperature": 0.2},
+	}
+
+	var ns, action string
+	var body map[string]interface{}
+	var format streamFormat
+	handler := RouteHandler(route, func(w http.ResponseWriter, r *http.Request) {
+		ns, action = getNamespaceAndAction(r)
+		format = negotiateFormat(r)
+		var err error
+		body, err = StreamConfig{}.injectStreamParams(r, map[string]string{"STREAM_HOST": "localhost"})
+		require.NoError(t, err)
+	})
+
+	req := httptest.NewRequest(http.MethodPost, "/chat?format=text", bytes.NewBufferString(`{"model": "large", "prompt": "hi"}`))
+	handler(httptest.NewRecorder(), req)
+
+	require.Equal(t, "prod", ns)
+	require.Equal(t, "llm/chat", action)
+	require.IsType(t, ndjsonFormat{}, format)
+	require.Equal(t, map[string]interface{}{
+		"model":       "large",
+		"temperature": 0.2,
+		"prompt":      "hi",
+		"STREAM_HOST": "localhost",
+	}, body)
+
+	handler = RouteHandler(Route{Namespace: "prod", Action: "hello"}, func(w http.ResponseWriter, r *http.Request) {
+		ns, action = getNamespaceAndAction(r)
+		format = negotiateFormat(r)
+	})
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/hello?format=ndjson", nil))
+	require.Equal(t, "hello", action)
+	require.IsType(t, ndjsonFormat{}, format)
+}
+
+func TestRouteSchema(t *testing.T) {
+	s, err := schema.Parse([]byte(`{"type": "object", "required": ["prompt"], "properties": {"max_tokens": {"type": "integer", "maximum": 100}}}`))
+	require.NoError(t, err)
+	// the defaults read from the YAML configuration are ints
+	route := Route{Namespace: "prod", Action: "chat", Params: map[string]interface{}{"max_tokens": 10}, Schema: s}
+
+	var body map[string]interface{}
+	var err2 error
+	handler := RouteHandler(route, func(w http.ResponseWriter, r *http.Request) {
+		body, err2 = StreamConfig{}.injectStreamParams(r, nil)
+	})
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(`{"prompt": "hi"}`)))
+	require.NoError(t, err2)
+	require.Equal(t, map[string]interface{}{"prompt": "hi", "max_tokens": 10.0}, body)
+
+	handler(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/chat", bytes.NewBufferString(`{"max_tokens": 1000}`)))
+	require.ErrorContains(t, err2, "prompt: is required")
+	require.ErrorContains(t, err2, "max_tokens: must be at most 100")
+}
@@ -19,136 +19,82 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
-	"strings"
 
+	"github.com/apache/openserverless-streaming-proxy/streams"
 	"github.com/apache/openserverless-streaming-proxy/tcp"
 )
 
-func WebActionStreamHandler(streamingProxyAddr string, apihost string) func(http.ResponseWriter, *http.Request) {
+func WebActionStreamHandler(cfg StreamConfig) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
-		ctx, done := context.WithCancel(r.Context())
+		ctx, done := context.WithCancel(streamContext(r))
 
 		namespace, actionToInvoke := getNamespaceAndAction(r)
 		log.Printf("Web Action requested: %s (%s)", actionToInvoke, namespace)
 
-		// opens a socket for listening in a random port
-		sock, err := tcp.SetupTcpServer(ctx, streamingProxyAddr)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		caller, ok := cfg.authenticateWeb(w, r, namespace)
+		if !ok {
 			done()
 			return
 		}
-
-		// parse the json body and add STREAM_HOST and STREAM_PORT
-		enrichedBody, err := injectHostPortInBody(r, sock.Host, sock.Port)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
+		r, release, ok := cfg.acquireStream(w, r, namespace, caller)
+		if !ok {
 			done()
 			return
 		}
+		defer release()
 
-		// invoke the action
-		actionToInvoke = ensurePackagePresent(actionToInvoke)
-
-		jsonData, err := json.Marshal(enrichedBody)
+		// opens a socket for listening in a random port
+		sock, err := tcp.SetupTcpServer(ctx, cfg.StreamerAddr, cfg.TCP)
 		if err != nil {
-			http.Error(w, "Error encoding JSON body: "+err.Error(), http.StatusInternalServerError)
+			socketError(w, err)
 			done()
 			return
 		}
-		url := fmt.Sprintf("%s/api/v1/web/%s/%s", apihost, namespace, actionToInvoke)
 
-		// Read headers and set them in the request
-		headers := make(map[string]string)
-		for key, values := range r.Header {
-			// Use the first value for the header
-			if len(values) > 0 {
-				headers[key] = values[0]
+		stream := streams.NewStream("web", namespace, actionToInvoke, clientIP(r), sock, done)
+		cfg.Registry.Add(stream)
+		// a polled stream outlives the request, and is removed when it ends
+		polled := false
+		defer func() {
+			if !polled {
+				cfg.Registry.Remove(stream.ID)
 			}
-		}
-
-		errChan := make(chan error)
-		defer close(errChan)
-		go asyncPostWebAction(errChan, url, jsonData, headers)
+		}()
 
-		// Flush the headers
-		flusher, ok := w.(http.Flusher)
-		if !ok {
-			http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		// parse the json body and add STREAM_HOST, STREAM_PORT and the TLS params
+		params := streamParams(cfg, stream, sock)
+		enrichedBody, err := cfg.injectStreamParams(r, params)
+		if err != nil {
+			paramsError(w, err)
 			done()
 			return
 		}
+		addClaims(enrichedBody, caller)
 
-		for {
-			select {
-			case data, isChannelOpen := <-sock.StreamDataChan:
-				if !isChannelOpen {
-					done()
-					return
-				}
-				_, err := w.Write([]byte(string(data) + "\n"))
-				if err != nil {
-					http.Error(w, "failed to write data: "+err.Error(), http.StatusInternalServerError)
-					done()
-					return
-				}
-				flusher.Flush()
-
-			case <-r.Context().Done():
-				log.Println("HTTP Client closed connection")
-				done()
-				return
-
-			case err := <-errChan:
-				log.Println("Error invoking action:", err)
-				http.Error(w, err.Error(), http.StatusInternalServerError)
-				done()
-				return
-			}
+		// invoke the action
+		results, err := cfg.invoker().Invoke(ctx, InvokeRequest{
+			Kind:         "web",
+			Namespace:    namespace,
+			Action:       actionToInvoke,
+			Params:       enrichedBody,
+			StreamParams: params,
+			Header:       r.Header,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			done()
+			return
 		}
-	}
-}
 
-func asyncPostWebAction(errChan chan error, url string, body []byte, headers map[string]string) {
-	bodyReader := strings.NewReader(string(body))
-
-	req, err := http.NewRequest("POST", ensureProtocolScheme(url), bodyReader)
-	if err != nil {
-		errChan <- err
-		return
-	}
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Content-Length", fmt.Sprintf("%d", bodyReader.Len()))
-	req.ContentLength = int64(bodyReader.Len())
-
-	// Aggiungi le intestazioni opzionali
-	for key, value := range headers {
-		req.Header.Set(key, value)
-	}
-
-	client := &http.Client{}
-	httpResp, err := client.Do(req)
-	if err != nil {
-		errChan <- err
-		return
-	}
+		if pollMode(r) {
+			polled = true
+			startPolling(w, r, cfg, stream, sock, results, done)
+			return
+		}
 
-	// We need to handle status in the range from 200 to 299
-	// as success, and everything else as an error.
-	// In particular, we need to handle 202 Accepted
-	// as a success, because the action is invoked
-	// asynchronously and the response is not available yet.
-	// We also need to handle 204 No Content as a success,
-	// because the action is invoked and there is no response.
-	// It seems that the invoker is releasing a 202 Accepted
-	// after 60 seconds, so we need to handle that as well.
-	if httpResp.StatusCode < 200 || httpResp.StatusCode >= 300 {
-		errChan <- fmt.Errorf("not ok (%s)", httpResp.Status)
-	} else {
-		log.Printf("Received status code %d: %s", httpResp.StatusCode, httpResp.Status)
+		relay(w, r, cfg, stream, sock, results)
+		done()
 	}
 }
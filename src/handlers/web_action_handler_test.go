@@ -27,6 +27,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/apache/openserverless-streaming-proxy/streams"
 	"github.com/stretchr/testify/require"
 )
 
@@ -52,19 +53,28 @@ func TestWebActionHandler(t *testing.T) {
 		port, ok := jsonData["STREAM_PORT"].(string)
 		require.True(t, ok)
 
+		marker, ok := jsonData["STREAM_END_MARKER"].(string)
+		require.True(t, ok)
+
 		msg := fmt.Sprintf("Invoked action: %s/%s/%s", namespace, pkg, action)
-		err = sendTcpSocketMsg(host, port, msg)
+		err = sendTcpSocketMsg(host, port, msg+marker+`{"ok": true}`)
 		require.NoError(t, err)
 
+		w.Header().Set("X-Openwhisk-Activation-Id", "abc123")
 		w.Write([]byte("ok"))
 	})
 	ts := httptest.NewServer(testMux)
 
+	cfg := StreamConfig{
+		APIHost:      ts.URL,
+		StreamerAddr: streamingProxyAddr,
+		Registry:     streams.NewRegistry(),
+	}
 	realMux := http.NewServeMux()
-	realMux.HandleFunc("POST /web/{ns}/{action}", WebActionStreamHandler(streamingProxyAddr, ts.URL))
-	realMux.HandleFunc("POST /web/{ns}/{pkg}/{action}", WebActionStreamHandler(streamingProxyAddr, ts.URL))
-	realMux.HandleFunc("GET /web/{ns}/{action}", WebActionStreamHandler(streamingProxyAddr, ts.URL))
-	realMux.HandleFunc("GET /web/{ns}/{pkg}/{action}", WebActionStreamHandler(streamingProxyAddr, ts.URL))
+	realMux.HandleFunc("POST /web/{ns}/{action}", WebActionStreamHandler(cfg))
+	realMux.HandleFunc("POST /web/{ns}/{pkg}/{action}", WebActionStreamHandler(cfg))
+	realMux.HandleFunc("GET /web/{ns}/{action}", WebActionStreamHandler(cfg))
+	realMux.HandleFunc("GET /web/{ns}/{pkg}/{action}", WebActionStreamHandler(cfg))
 
 	server := httptest.NewServer(realMux)
 
@@ -82,8 +92,11 @@ func TestWebActionHandler(t *testing.T) {
 		defer resp.Body.Close()
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(resp.Body)
-		require.Equal(t, "Invoked action: testns/default/testaction\n", buf.String())
+		require.Equal(t, "Invoked action: testns/default/testaction\n[stream end] {\"ok\": true}\n", buf.String())
 		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "complete", resp.Trailer.Get("X-Stream-Status"))
+		require.Equal(t, "abc123", resp.Trailer.Get("X-Activation-Id"))
+		require.Equal(t, "41", resp.Trailer.Get("X-Stream-Bytes"))
 	})
 
 	t.Run("get", func(t *testing.T) {
@@ -94,8 +107,34 @@ func TestWebActionHandler(t *testing.T) {
 		defer resp.Body.Close()
 		buf := new(bytes.Buffer)
 		buf.ReadFrom(resp.Body)
-		require.Equal(t, "Invoked action: testns/default/testaction\n", buf.String())
+		require.Equal(t, "Invoked action: testns/default/testaction\n[stream end] {\"ok\": true}\n", buf.String())
 		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.Equal(t, "complete", resp.Trailer.Get("X-Stream-Status"))
+		require.Equal(t, "abc123", resp.Trailer.Get("X-Activation-Id"))
+		require.Equal(t, "41", resp.Trailer.Get("X-Stream-Bytes"))
+	})
+
+	t.Run("get ndjson", func(t *testing.T) {
+		resp, err := http.Get(server.URL + "/web/testns/testaction?format=ndjson")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+
+		decoder := json.NewDecoder(resp.Body)
+		var record map[string]interface{}
+		require.NoError(t, decoder.Decode(&record))
+		require.Equal(t, "data", record["type"])
+		require.Equal(t, 1.0, record["seq"])
+		require.Equal(t, "Invoked action: testns/default/testaction", record["data"])
+
+		record = nil
+		require.NoError(t, decoder.Decode(&record))
+		require.Equal(t, "end", record["type"])
+		require.Equal(t, 2.0, record["seq"])
+		require.Equal(t, 41.0, record["bytes"])
+		require.Equal(t, "abc123", record["activation_id"])
+		require.Equal(t, map[string]interface{}{"ok": true}, record["summary"])
+		require.False(t, decoder.More())
 	})
 }
 
@@ -107,6 +146,8 @@ func TestAsyncPostWebAction(t *testing.T) {
 		headers        map[string]string
 		expectedErrMsg []string
 		handler        http.HandlerFunc
+
+		expectedActivationID string
 	}{
 		{
 			name: "Successful request",
@@ -116,8 +157,10 @@ func TestAsyncPostWebAction(t *testing.T) {
 				"Content-Type": "application/json",
 			},
 			handler: func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("X-Openwhisk-Activation-Id", "abc123")
 				w.WriteHeader(http.StatusOK)
 			},
+			expectedActivationID: "abc123",
 		},
 		{
 			name: "Error in request creation",
@@ -144,7 +187,7 @@ func TestAsyncPostWebAction(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			errChan := make(chan error, 1)
+			results := make(chan Invocation, 1)
 
 			if tt.handler != nil {
 				server := httptest.NewServer(tt.handler)
@@ -152,9 +195,11 @@ func TestAsyncPostWebAction(t *testing.T) {
 				tt.url = server.URL + tt.url
 			}
 
-			asyncPostWebAction(errChan, tt.url, tt.body, tt.headers)
+			asyncPostWebAction(results, tt.url, tt.body, tt.headers)
 			select {
-			case err := <-errChan:
+			case res := <-results:
+				err := res.Err
+				require.Equal(t, tt.expectedActivationID, res.ActivationID)
 				if len(tt.expectedErrMsg) > 0 {
 					require.NotEmpty(t, tt.expectedErrMsg)
 					matched := false
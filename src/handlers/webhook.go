@@ -0,0 +1,79 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// WebhookInvoker posts the parameters of the actions, as JSON, to URL, for
+// example a Knative service. {namespace} and {action} in URL are replaced by
+// those of the action, which are also sent in the X-Stream-Namespace and
+// X-Stream-Action headers.
+type WebhookInvoker struct {
+	URL    string
+	Client *http.Client
+}
+
+func (h *WebhookInvoker) Invoke(ctx context.Context, req InvokeRequest) (<-chan Invocation, error) {
+	body, err := json.Marshal(req.Params)
+	if err != nil {
+		return nil, fmt.Errorf("Error encoding JSON body: %w", err)
+	}
+	target := strings.NewReplacer(
+		"{namespace}", url.PathEscape(req.Namespace),
+		"{action}", req.Action,
+	).Replace(h.URL)
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	httpReq.Header.Set("X-Stream-Kind", req.Kind)
+	httpReq.Header.Set("X-Stream-Namespace", req.Namespace)
+	httpReq.Header.Set("X-Stream-Action", req.Action)
+
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	// the service may answer only once it is done streaming
+	results := make(chan Invocation, 1)
+	go func() {
+		resp, err := client.Do(httpReq)
+		if err != nil {
+			results <- Invocation{Err: err}
+			return
+		}
+		resp.Body.Close()
+		result := Invocation{ActivationID: resp.Header.Get("X-Activation-Id")}
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			result.Err = fmt.Errorf("not ok (%s)", resp.Status)
+		}
+		results <- result
+	}()
+	return results, nil
+}
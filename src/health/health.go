@@ -0,0 +1,192 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+const checkTimeout = 2 * time.Second
+
+// apiHostTTL is how long the outcome of the API host check is reused, not to
+// probe OpenWhisk on every readiness request.
+const apiHostTTL = 5 * time.Second
+
+type CheckResult struct {
+	Name     string `json:"name"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+type Report struct {
+	Status string        `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+func (r Report) Ready() bool {
+	return r.Status == "ok"
+}
+
+// Checker tells whether the streamer can serve new streams.
+type Checker struct {
+	mu           sync.RWMutex
+	apihost      string
+	streamerAddr string
+	sockets      tcp.Options
+	client       *http.Client
+	draining     atomic.Bool
+
+	// the last outcome of the API host check
+	apiHostMu    sync.Mutex
+	apiHostTTL   time.Duration
+	apiHostAt    time.Time
+	apiHostOf    string
+	apiHostError error
+}
+
+func NewChecker(apihost string, streamerAddr string) *Checker {
+	return &Checker{
+		apihost:      apihost,
+		streamerAddr: streamerAddr,
+		client:       &http.Client{Timeout: checkTimeout},
+		apiHostTTL:   apiHostTTL,
+	}
+}
+
+// SetTargets changes the API host and the listener address being checked,
+// with the options of the action sockets, as their port range or directory.
+func (c *Checker) SetTargets(apihost string, streamerAddr string, sockets tcp.Options) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apihost = apihost
+	c.streamerAddr = streamerAddr
+	c.sockets = sockets
+}
+
+func (c *Checker) targets() (string, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.apihost, c.streamerAddr
+}
+
+// StartDrain marks the streamer as shutting down, so it is no longer ready.
+func (c *Checker) StartDrain() {
+	c.draining.Store(true)
+}
+
+func (c *Checker) Draining() bool {
+	return c.draining.Load()
+}
+
+// Check runs all the readiness checks.
+func (c *Checker) Check(ctx context.Context) Report {
+	type check struct {
+		name string
+		run  func(context.Context) error
+	}
+	checks := []check{
+		{"tcp_listener", c.checkListener},
+		{"drain", c.checkDrain},
+	}
+	// without an API host, the actions do not run on OpenWhisk
+	if apihost, _ := c.targets(); apihost != "" {
+		checks = append([]check{{"openwhisk", c.checkAPIHost}}, checks...)
+	}
+
+	report := Report{Status: "ok", Checks: []CheckResult{}}
+	for _, check := range checks {
+		start := time.Now()
+		err := check.run(ctx)
+		result := CheckResult{
+			Name:     check.name,
+			Status:   "ok",
+			Duration: time.Since(start).Round(time.Microsecond).String(),
+		}
+		if err != nil {
+			result.Status = "fail"
+			result.Error = err.Error()
+			report.Status = "fail"
+		}
+		report.Checks = append(report.Checks, result)
+	}
+	return report
+}
+
+// checkAPIHost probes the API host, reusing the outcome of the last probe
+// for a few seconds.
+func (c *Checker) checkAPIHost(ctx context.Context) error {
+	url, _ := c.targets()
+	c.apiHostMu.Lock()
+	defer c.apiHostMu.Unlock()
+	if c.apiHostOf == url && time.Since(c.apiHostAt) < c.apiHostTTL {
+		return c.apiHostError
+	}
+	err := c.probeAPIHost(ctx, url)
+	// the client of the check going away says nothing of the API host
+	if ctx.Err() == nil {
+		c.apiHostOf, c.apiHostAt, c.apiHostError = url, time.Now(), err
+	}
+	return err
+}
+
+// probeAPIHost considers the OpenWhisk API host reachable when it answers
+// anything other than a server error.
+func (c *Checker) probeAPIHost(ctx context.Context, url string) error {
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		url = "https://" + url
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", url+"/api/v1", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("not ok (%s)", resp.Status)
+	}
+	return nil
+}
+
+// checkListener opens a socket as for a stream, so that an exhausted port
+// range, or a socket directory gone, makes the streamer not ready.
+func (c *Checker) checkListener(ctx context.Context) error {
+	c.mu.RLock()
+	streamerAddr, sockets := c.streamerAddr, c.sockets
+	c.mu.RUnlock()
+	return tcp.Probe(streamerAddr, sockets)
+}
+
+func (c *Checker) checkDrain(ctx context.Context) error {
+	if c.Draining() {
+		return errors.New("shutting down")
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package health
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	apiStatus := http.StatusUnauthorized
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/api/v1", r.URL.Path)
+		w.WriteHeader(apiStatus)
+	}))
+	defer ts.Close()
+
+	checker := NewChecker(ts.URL, "localhost")
+	checker.apiHostTTL = 0
+
+	report := checker.Check(context.Background())
+	require.True(t, report.Ready())
+	require.Len(t, report.Checks, 3)
+	for _, check := range report.Checks {
+		require.Equal(t, "ok", check.Status, check.Name)
+	}
+
+	apiStatus = http.StatusBadGateway
+	report = checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, "openwhisk", report.Checks[0].Name)
+	require.Equal(t, "not ok (502 Bad Gateway)", report.Checks[0].Error)
+
+	apiStatus = http.StatusOK
+	checker.StartDrain()
+	report = checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, "drain", report.Checks[2].Name)
+	require.Equal(t, "fail", report.Checks[2].Status)
+}
+
+func TestCheckFailures(t *testing.T) {
+	checker := NewChecker("http://127.0.0.1:1", "invalid address")
+
+	report := checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, "fail", report.Checks[0].Status)
+	require.Equal(t, "fail", report.Checks[1].Status)
+	require.Equal(t, "ok", report.Checks[2].Status)
+}
+
+func TestCheckAPIHostCached(t *testing.T) {
+	var probes atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer ts.Close()
+
+	checker := NewChecker(ts.URL, "localhost")
+	for range 3 {
+		report := checker.Check(context.Background())
+		require.Equal(t, "not ok (502 Bad Gateway)", report.Checks[0].Error)
+	}
+	require.Equal(t, int32(1), probes.Load())
+
+	// another API host is probed again
+	checker.SetTargets(ts.URL+"/", "localhost", tcp.Options{})
+	checker.Check(context.Background())
+	require.Equal(t, int32(2), probes.Load())
+}
+
+func TestCheckListenerSockets(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer l.Close()
+	port := l.Addr().(*net.TCPAddr).Port
+
+	// the port range of the action sockets is exhausted
+	checker := NewChecker("", "127.0.0.1")
+	checker.SetTargets("", "127.0.0.1", tcp.Options{Ports: tcp.PortRange{Min: port, Max: port}})
+	report := checker.Check(context.Background())
+	require.False(t, report.Ready())
+	require.Equal(t, "tcp_listener", report.Checks[0].Name)
+	require.Contains(t, report.Checks[0].Error, tcp.ErrNoFreePort.Error())
+
+	dir := t.TempDir()
+	checker.SetTargets("", "127.0.0.1", tcp.Options{SocketDir: dir})
+	require.True(t, checker.Check(context.Background()).Ready())
+	checker.SetTargets("", "127.0.0.1", tcp.Options{SocketDir: filepath.Join(dir, "missing")})
+	require.False(t, checker.Check(context.Background()).Ready())
+}
+
+func TestCheckWithoutAPIHost(t *testing.T) {
+	checker := NewChecker("", "localhost")
+
+	report := checker.Check(context.Background())
+	require.True(t, report.Ready())
+	require.Len(t, report.Checks, 2)
+	require.Equal(t, "tcp_listener", report.Checks[0].Name)
+}
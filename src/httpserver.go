@@ -18,82 +18,311 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/apache/openserverless-streaming-proxy/certs"
+	"github.com/apache/openserverless-streaming-proxy/config"
 	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/health"
+	"github.com/apache/openserverless-streaming-proxy/jwt"
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/schema"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/apache/openserverless-streaming-proxy/vault"
 )
 
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+// newCORS wraps next with the CORS policy of cfg.
+func newCORS(cfg config.CORSConfig, next http.Handler) http.Handler {
+	routes := make([]handlers.CORSRoute, len(cfg.Routes))
+	for i, route := range cfg.Routes {
+		routes[i] = handlers.CORSRoute{Path: route.Path, Policy: corsPolicy(cfg.Override(route))}
+	}
+	return handlers.WithCORS(corsPolicy(cfg), routes, next)
+}
+
+func corsPolicy(cfg config.CORSConfig) handlers.CORSPolicy {
+	return handlers.CORSPolicy{
+		AllowOrigins:     cfg.Origins(),
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge.Duration(),
+	}
+}
+
+// newStreamConfig prepares what the stream handlers need for cfg.
+func newStreamConfig(cfg *config.Config, tcpOptions tcp.Options, auth handlers.Authenticator, webAuth handlers.Authenticator, streamLimiter *limiter.Limiter, rec *recorder.Recorder, registry *streams.Registry, polls *handlers.PollSessions) handlers.StreamConfig {
+	return handlers.StreamConfig{
+		Invoker:           newInvoker(cfg),
+		APIHost:           cfg.APIHost,
+		Auth:              auth,
+		WebAuth:           webAuth,
+		AuthQuery:         cfg.Auth.Query,
+		AuthCookie:        cfg.Auth.Cookie,
+		Limiter:           streamLimiter,
+		StreamerAddr:      cfg.BindAddr(),
+		IngestBaseURL:     cfg.Stream.IngestBaseURL,
+		HeartbeatInterval: cfg.Stream.Heartbeat.Interval.Duration(),
+		HeartbeatMessage:  cfg.Stream.Heartbeat.Message(),
+		IdleTimeout:       cfg.Stream.IdleTimeout.Duration(),
+		MaxBodySize:       int64(cfg.Params.MaxBodySize),
+		ReservedParams:    cfg.Params.Reserved,
+		Recorder:          rec,
+		Registry:          registry,
+		Polls:             polls,
+		TCP:               tcpOptions,
+	}
+}
 
-		// Set CORS headers
-		allowOrigin := os.Getenv("CORS_ALLOW_ORIGIN")
-		if allowOrigin == "" {
-			allowOrigin = "*"
+// newInvoker returns what runs the actions, as configured by cfg.Backend.
+func newInvoker(cfg *config.Config) handlers.Invoker {
+	switch cfg.Backend.Type {
+	case "webhook":
+		return &handlers.WebhookInvoker{URL: cfg.Backend.Webhook.URL}
+	case "process":
+		return &handlers.ProcessInvoker{
+			Command: strings.Fields(cfg.Backend.Process.Command),
+			Dir:     cfg.Backend.Process.Dir,
 		}
-		w.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+	default:
+		return &handlers.OpenWhiskInvoker{APIHost: cfg.APIHost}
+	}
+}
 
-		allowMethods := os.Getenv("CORS_ALLOW_METHODS")
-		if allowMethods == "" {
-			allowMethods = "GET, POST, OPTIONS"
+// newAuthenticators return what authenticates the callers of the actions,
+// and of the web actions, as configured by cfg.Auth. Both are nil in
+// passthrough mode. Without a JWKS file, the tokens are verified with
+// issuerKeys.
+func newAuthenticators(cfg *config.Config, issuerKeys *jwt.IssuerKeys) (handlers.Authenticator, handlers.Authenticator, error) {
+	if cfg.Auth.Mode == "passthrough" {
+		return nil, nil, nil
+	}
+	keys, err := vault.Open(cfg.Auth.Vault.File, cfg.Auth.Vault.Dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cfg.Auth.Mode == "vault" {
+		callers, err := vault.LoadCallers(cfg.Auth.CallersFile)
+		if err != nil {
+			return nil, nil, err
 		}
-		w.Header().Set("Access-Control-Allow-Methods", allowMethods)
+		return &handlers.VaultAuthenticator{Callers: callers, Vault: keys}, nil, nil
+	}
 
-		allowHeaders := os.Getenv("CORS_ALLOW_HEADERS")
-		if allowHeaders == "" {
-			allowHeaders = "*"
+	jwtConfig := cfg.Auth.JWT
+	verifier := &jwt.Verifier{
+		Issuer:   jwtConfig.Issuer,
+		Audience: jwtConfig.Audience,
+		Leeway:   jwtConfig.Leeway.Duration(),
+	}
+	if jwtConfig.JWKSFile != "" {
+		keySet, err := jwt.LoadKeySet(jwtConfig.JWKSFile)
+		if err != nil {
+			return nil, nil, err
 		}
-		w.Header().Set("Access-Control-Allow-Headers", allowHeaders)
+		verifier.Keys = keySet
+	} else {
+		verifier.Keys = issuerKeys
+	}
+	webAuth := &handlers.JWTAuthenticator{
+		Verifier: verifier,
+		Rules:    jwtConfig.Rules,
+		Forward:  jwtConfig.ForwardClaims,
+	}
+	auth := *webAuth
+	auth.Vault = keys
+	return &auth, webAuth, nil
+}
 
-		// Handle preflight request
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusNoContent)
-			return
+// newRouter builds the public handler for cfg, loading the schemas of the
+// routes. It is built again on every
+// configuration reload, while the open streams keep running on the old one.
+// Conflicting patterns are returned as an error, not a panic, so that a bad
+// reload keeps the current router.
+func newRouter(cfg *config.Config, streamConfig handlers.StreamConfig, checker *health.Checker) (handler http.Handler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			handler, err = nil, fmt.Errorf("routes: %v", r)
 		}
+	}()
+	router := http.NewServeMux()
 
-		next.ServeHTTP(w, r)
+	router.HandleFunc("GET /{$}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("Streamer proxy running"))
 	})
-}
+	router.HandleFunc("GET /healthz", handlers.HealthzHandler())
+	router.HandleFunc("GET /readyz", handlers.ReadyzHandler(checker))
+
+	router.HandleFunc("POST /ingest/{id}", handlers.IngestHandler(streamConfig.Registry))
+	router.HandleFunc("GET /poll/{id}", handlers.PollHandler(streamConfig.Polls))
 
-func startHTTPServer(streamingProxyAddr string, apihost string) {
-	httpPort := os.Getenv("HTTP_SERVER_PORT")
-	if httpPort == "" {
-		httpPort = "80"
+	if cfg.Routes.Web {
+		webHandler := handlers.WebActionStreamHandler(streamConfig)
+		router.HandleFunc("GET /web/{ns}/{action}", webHandler)
+		router.HandleFunc("GET /web/{ns}/{pkg}/{action}", webHandler)
+		router.HandleFunc("POST /web/{ns}/{action}", webHandler)
+		router.HandleFunc("POST /web/{ns}/{pkg}/{action}", webHandler)
 	}
 
-	router := http.NewServeMux()
+	if cfg.Routes.Action {
+		actionHandler := handlers.ActionStreamHandler(streamConfig)
+		router.HandleFunc("GET /action/{ns}/{action}", actionHandler)
+		router.HandleFunc("GET /action/{ns}/{pkg}/{action}", actionHandler)
+		router.HandleFunc("POST /action/{ns}/{action}", actionHandler)
+		router.HandleFunc("POST /action/{ns}/{pkg}/{action}", actionHandler)
+	}
 
-	router.HandleFunc("GET /", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte("Streamer proxy running"))
-	})
+	for _, route := range cfg.Routes.Table {
+		var paramsSchema *schema.Schema
+		if route.Schema != "" {
+			if paramsSchema, err = schema.Load(route.Schema); err != nil {
+				return nil, fmt.Errorf("schema of route %s: %w", route.Path, err)
+			}
+		}
+		streamHandler := handlers.ActionStreamHandler(streamConfig)
+		if route.Type == "web" {
+			streamHandler = handlers.WebActionStreamHandler(streamConfig)
+		}
+		routeHandler := handlers.RouteHandler(handlers.Route{
+			Namespace: route.Namespace,
+			Action:    route.Action,
+			Format:    route.Format,
+			Params:    route.Params,
+			Schema:    paramsSchema,
+		}, streamHandler)
+		for _, method := range route.AllowedMethods() {
+			router.HandleFunc(method+" "+route.Path, routeHandler)
+		}
+	}
 
-	router.HandleFunc("GET /web/{ns}/{action}", handlers.WebActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("GET /web/{ns}/{pkg}/{action}", handlers.WebActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("GET /action/{ns}/{action}", handlers.ActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("GET /action/{ns}/{pkg}/{action}", handlers.ActionStreamHandler(streamingProxyAddr, apihost))
+	if cfg.CORS.Enabled {
+		return newCORS(cfg.CORS, router), nil
+	}
+	return router, nil
+}
 
-	router.HandleFunc("POST /web/{ns}/{action}", handlers.WebActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("POST /web/{ns}/{pkg}/{action}", handlers.WebActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("POST /action/{ns}/{action}", handlers.ActionStreamHandler(streamingProxyAddr, apihost))
-	router.HandleFunc("POST /action/{ns}/{pkg}/{action}", handlers.ActionStreamHandler(streamingProxyAddr, apihost))
+// newTCPOptions prepares the options of the sockets the actions stream to.
+func newTCPOptions(stream config.StreamConfig) (tcp.Options, error) {
+	opts := tcp.Options{
+		AdvertiseHost: stream.AdvertiseHost,
+		Ports:         stream.Ports(),
+		SocketDir:     stream.SocketDir,
+		SocketMode:    stream.SocketFileMode(),
+		KeepAlive:     stream.TCPKeepAlive.Duration(),
+	}
+	if opts.KeepAlive == 0 {
+		opts.KeepAlive = -1
+	}
+	if stream.SocketDir != "" {
+		info, err := os.Stat(stream.SocketDir)
+		if err != nil {
+			return opts, err
+		}
+		if !info.IsDir() {
+			return opts, fmt.Errorf("stream.socket_dir %s is not a directory", stream.SocketDir)
+		}
+	}
+	if stream.SocketGroup != "" {
+		gid, err := lookupGroup(stream.SocketGroup)
+		if err != nil {
+			return opts, fmt.Errorf("stream.socket_group: %w", err)
+		}
+		opts.SocketGID = gid
+	}
+	if stream.TLS.Enabled() {
+		reloader, err := certs.NewReloader(stream.TLS.CertFile, stream.TLS.KeyFile, "")
+		if err != nil {
+			return opts, err
+		}
+		opts.TLS = reloader
+	}
+	return opts, nil
+}
 
-	corsEnabled := os.Getenv("CORS_ENABLED")
-	useCors := corsEnabled == "1" || corsEnabled == "true"
+// lookupGroup returns the gid of group, a name or a gid.
+func lookupGroup(group string) (int, error) {
+	if gid, err := strconv.Atoi(group); err == nil {
+		return gid, nil
+	}
+	g, err := user.LookupGroup(group)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(g.Gid)
+}
+
+// startHTTPServer serves until ctx is done, then stops accepting new streams
+// and waits up to the shutdown timeout for the active ones to end.
+func startHTTPServer(ctx context.Context, rl *reloader, checker *health.Checker) {
+	cfg := rl.Config()
+	httpPort := strconv.Itoa(cfg.HTTP.Port)
 
 	server := &http.Server{
-		Addr: ":" + httpPort,
-		Handler: func() http.Handler {
-			if useCors {
-				return corsMiddleware(router)
-			}
-			return router
-		}(),
+		Addr:              ":" + httpPort,
+		ReadHeaderTimeout: cfg.Timeouts.ReadHeader.Duration(),
+		Handler:           rl,
 	}
 
-	log.Println("HTTP server listening on port", httpPort)
-	if err := server.ListenAndServe(); err != nil {
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-ctx.Done()
+
+		// the timeouts may have been reloaded in the meantime
+		timeouts := rl.Config().Timeouts
+
+		// fail the readiness check first, so that no new traffic is routed here
+		checker.StartDrain()
+		log.Printf("Shutting down, draining for %s", timeouts.DrainDelay)
+		time.Sleep(timeouts.DrainDelay.Duration())
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), timeouts.Shutdown.Duration())
+		defer cancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			log.Println("Error shutting down HTTP server:", err)
+		}
+	}()
+
+	var err error
+	if cfg.HTTP.TLS.Enabled() {
+		err = listenAndServeTLS(server, cfg.HTTP.TLS)
+	} else {
+		log.Println("HTTP server listening on port", httpPort)
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		<-shutdownDone
+		log.Println("HTTP server stopped")
+	} else if err != nil {
 		log.Println("Error starting HTTP server:", err)
 	}
 }
+
+// listenAndServeTLS serves HTTPS and HTTP/2, picking up rotated certificates
+// without a restart.
+func listenAndServeTLS(server *http.Server, tlsConfig config.TLSConfig) error {
+	clientAuth, err := certs.ParseClientAuth(tlsConfig.ClientAuth)
+	if err != nil {
+		return err
+	}
+	reloader, err := certs.NewReloader(tlsConfig.CertFile, tlsConfig.KeyFile, tlsConfig.ClientCAFile)
+	if err != nil {
+		return err
+	}
+	server.TLSConfig = reloader.ServerConfig(clientAuth)
+
+	log.Printf("HTTPS server listening on port %s (client certificates: %s)", server.Addr[1:], tlsConfig.ClientAuth)
+	return server.ListenAndServeTLS("", "")
+}
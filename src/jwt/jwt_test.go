@@ -0,0 +1,238 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	require.NoError(t, err)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// sign makes a token with claims, signed by key with alg.
+func sign(t *testing.T, alg string, kid string, key crypto.Signer, claims map[string]interface{}) string {
+	signed := encodeSegment(t, map[string]string{"alg": alg, "kid": kid, "typ": "JWT"}) + "." + encodeSegment(t, claims)
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	}
+	digest := []byte(signed)
+	if hash != 0 {
+		h := hash.New()
+		h.Write([]byte(signed))
+		digest = h.Sum(nil)
+	}
+
+	var signature []byte
+	var err error
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest)
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature = append(r.FillBytes(make([]byte, size)), s.FillBytes(make([]byte, size))...)
+	case *rsa.PrivateKey:
+		if alg[:2] == "PS" {
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest, nil)
+		} else {
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		}
+	default:
+		signature, err = key.Sign(rand.Reader, digest, crypto.Hash(0))
+	}
+	require.NoError(t, err)
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func encodeInt(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
+
+// testKeys returns signing keys of every type, and their JWKS document.
+func testKeys(t *testing.T) (map[string]crypto.Signer, []byte) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]interface{}{"keys": []map[string]string{
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": encodeInt(rsaKey.N), "e": encodeInt(big.NewInt(int64(rsaKey.E)))},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": encodeInt(ecKey.X), "y": encodeInt(ecKey.Y)},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": base64.RawURLEncoding.EncodeToString(edKey.Public().(ed25519.PublicKey))},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": encodeInt(rsaKey.N), "e": "AQAB"},
+		{"kty": "oct", "kid": "secret", "k": "c2VjcmV0"},
+	}})
+	require.NoError(t, err)
+	return map[string]crypto.Signer{"rsa": rsaKey, "ec": ecKey, "ed": edKey}, jwks
+}
+
+func TestVerify(t *testing.T) {
+	signers, jwks := testKeys(t)
+	keys, err := ParseKeySet(jwks)
+	require.NoError(t, err)
+	require.Len(t, keys, 3)
+
+	now := time.Unix(1_700_000_000, 0)
+	v := &Verifier{Keys: keys, Issuer: "https://id.example.com", Audience: "streamer", Leeway: time.Minute, now: func() time.Time { return now }}
+	valid := map[string]interface{}{
+		"iss":   "https://id.example.com",
+		"aud":   []string{"streamer", "other"},
+		"sub":   "user-1",
+		"exp":   now.Add(time.Hour).Unix(),
+		"scope": "openid stream:invoke",
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		claims := map[string]interface{}{}
+		for k, v := range valid {
+			claims[k] = v
+		}
+		claims[key] = value
+		return claims
+	}
+
+	for _, tt := range []struct{ alg, kid string }{{"RS256", "rsa"}, {"PS256", "rsa"}, {"ES256", "ec"}, {"EdDSA", "ed"}} {
+		claims, err := v.Verify(context.Background(), sign(t, tt.alg, tt.kid, signers[tt.kid], valid))
+		require.NoError(t, err, tt.alg)
+		require.Equal(t, "user-1", claims["sub"])
+		require.Equal(t, []string{"openid", "stream:invoke"}, claims.Strings("scope"))
+		require.Equal(t, []string{"streamer", "other"}, claims.Strings("aud"))
+	}
+
+	tampered := sign(t, "RS256", "rsa", signers["rsa"], valid)
+	parts := strings.Split(tampered, ".")
+	parts[1] = encodeSegment(t, with("sub", "admin"))
+
+	invalid := map[string]string{
+		"expired":         sign(t, "RS256", "rsa", signers["rsa"], with("exp", now.Add(-2*time.Minute).Unix())),
+		"not yet valid":   sign(t, "RS256", "rsa", signers["rsa"], with("nbf", now.Add(2*time.Minute).Unix())),
+		"other issuer":    sign(t, "RS256", "rsa", signers["rsa"], with("iss", "https://evil.example.com")),
+		"other audience":  sign(t, "RS256", "rsa", signers["rsa"], with("aud", "other")),
+		"unknown key":     sign(t, "RS256", "other", signers["rsa"], valid),
+		"wrong algorithm": sign(t, "ES256", "rsa", signers["ec"], valid),
+		"tampered":        strings.Join(parts, "."),
+		"unsigned":        encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, valid) + ".",
+		"not a jwt":       "uuid:key",
+	}
+	for name, token := range invalid {
+		_, err := v.Verify(context.Background(), token)
+		require.ErrorIs(t, err, ErrInvalidToken, name)
+	}
+}
+
+func TestIssuerKeys(t *testing.T) {
+	signers, jwks := testKeys(t)
+	var fetches atomic.Int32
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Write(jwks)
+	})
+
+	v := &Verifier{Keys: &IssuerKeys{Issuer: server.URL, TTL: time.Hour}, Issuer: server.URL}
+	claims := map[string]interface{}{"iss": server.URL, "exp": time.Now().Add(time.Hour).Unix()}
+	for kid, alg := range map[string]string{"rsa": "RS256", "ec": "ES256"} {
+		_, err := v.Verify(context.Background(), sign(t, alg, kid, signers[kid], claims))
+		require.NoError(t, err, kid)
+	}
+	require.Equal(t, int32(1), fetches.Load())
+
+	// an unknown key id does not fetch the keys again right away
+	_, err := v.Verify(context.Background(), sign(t, "RS256", "other", signers["rsa"], claims))
+	require.ErrorIs(t, err, ErrInvalidToken)
+	require.Equal(t, int32(1), fetches.Load())
+
+	// the keys are fetched again as long as none could be
+	v = &Verifier{Keys: &IssuerKeys{Issuer: server.URL + "/missing", TTL: time.Hour}}
+	for range 2 {
+		_, err = v.Verify(context.Background(), sign(t, "RS256", "rsa", signers["rsa"], claims))
+		require.ErrorContains(t, err, "404 Not Found")
+	}
+}
+
+func TestIssuerKeysFetch(t *testing.T) {
+	signers, jwks := testKeys(t)
+	var fetches atomic.Int32
+	release := make(chan struct{})
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": server.URL, "jwks_uri": server.URL + "/keys"})
+	})
+	mux.HandleFunc("GET /keys", func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		<-release
+		w.Write(jwks)
+	})
+
+	keys := &IssuerKeys{Issuer: server.URL, TTL: time.Hour}
+	v := &Verifier{Keys: keys, Issuer: server.URL}
+	token := sign(t, "RS256", "rsa", signers["rsa"], map[string]interface{}{"iss": server.URL, "exp": time.Now().Add(time.Hour).Unix()})
+
+	// a client going away stops waiting for the keys, not their fetch
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := v.Verify(ctx, token)
+	require.ErrorContains(t, err, context.Canceled.Error())
+
+	// the requests waiting at the same time share the fetch
+	errs := make(chan error, 3)
+	for range cap(errs) {
+		go func() {
+			_, err := v.Verify(context.Background(), token)
+			errs <- err
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	for range cap(errs) {
+		require.NoError(t, <-errs)
+	}
+	require.Equal(t, int32(1), fetches.Load())
+}
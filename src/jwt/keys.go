@@ -0,0 +1,318 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package jwt
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KeySource gives the public key a token was signed with, by key id.
+type KeySource interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// KeySet holds the keys of a JWKS document, by key id.
+type KeySet map[string]crypto.PublicKey
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// ParseKeySet reads a JWKS document. The keys that are not signing keys of
+// a supported type are skipped.
+func ParseKeySet(data []byte) (KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	keys := KeySet{}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		key, err := k.publicKey()
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", k.Kid, err)
+		}
+		if key != nil {
+			keys[k.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+// LoadKeySet reads a JWKS file.
+func LoadKeySet(file string) (KeySet, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing JWKS file %s: %w", file, err)
+	}
+	return keys, nil
+}
+
+// Key returns the key kid or, for tokens without a kid, the only key of
+// the set.
+func (s KeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown key %q", kid)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, nil
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, errors.New("point not on the curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, nil
+	}
+}
+
+func decodeInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("missing value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+const (
+	// minRefresh limits how often the keys of an issuer are fetched again,
+	// for unknown key ids or after a failure.
+	minRefresh = time.Minute
+
+	fetchTimeout = 10 * time.Second
+)
+
+// IssuerKeys fetches the keys of an OpenID issuer, from the jwks_uri of its
+// discovery document, caching them for TTL. An unknown key id fetches them
+// again, at most once a minute, for the keys rotated in the meantime. Until
+// some keys are fetched, every lookup tries again. The requests looking up
+// keys at the same time share a single fetch, which outlives them.
+type IssuerKeys struct {
+	Issuer string
+	TTL    time.Duration
+	Client *http.Client
+
+	mu        sync.Mutex
+	keys      KeySet
+	fetched   time.Time
+	attempted time.Time
+	err       error
+	// fetching is closed when the fetch in progress, if any, is over
+	fetching chan struct{}
+}
+
+// errRecentFetch is returned by refresh when the keys were fetched less
+// than a minute ago.
+var errRecentFetch = errors.New("keys fetched recently")
+
+func (k *IssuerKeys) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	k.mu.Lock()
+	keys, stale := k.keys, k.keys == nil || time.Since(k.fetched) > k.TTL
+	k.mu.Unlock()
+
+	if stale {
+		if err := k.refresh(ctx); err != nil && keys == nil {
+			return nil, err
+		}
+		keys = k.current()
+	}
+	key, err := keys.Key(ctx, kid)
+	if err != nil && k.refresh(ctx) == nil {
+		return k.current().Key(ctx, kid)
+	}
+	return key, err
+}
+
+func (k *IssuerKeys) current() KeySet {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.keys
+}
+
+// refresh fetches the keys again, or waits for the fetch in progress, unless
+// some were fetched and it was tried less than a minute ago. On failure, the
+// keys fetched before are kept.
+func (k *IssuerKeys) refresh(ctx context.Context) error {
+	k.mu.Lock()
+	if k.fetching == nil {
+		if k.keys != nil && time.Since(k.attempted) < minRefresh {
+			k.mu.Unlock()
+			return errRecentFetch
+		}
+		k.attempted = time.Now()
+		k.fetching = make(chan struct{})
+		go k.fetchKeys(k.fetching)
+	}
+	fetching := k.fetching
+	k.mu.Unlock()
+
+	select {
+	case <-fetching:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.err
+}
+
+// fetchKeys fetches the keys for all the requests waiting for them, closing
+// done once over.
+func (k *IssuerKeys) fetchKeys(done chan struct{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+	keys, err := k.fetch(ctx)
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if err != nil {
+		log.Printf("Fetching the keys of %s failed: %s", k.Issuer, err)
+	} else {
+		k.keys, k.fetched = keys, time.Now()
+	}
+	k.err = err
+	k.fetching = nil
+	close(done)
+}
+
+func (k *IssuerKeys) fetch(ctx context.Context) (KeySet, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	if err := k.get(ctx, strings.TrimSuffix(k.Issuer, "/")+"/.well-known/openid-configuration", &discovery); err != nil {
+		return nil, err
+	}
+	if discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("no jwks_uri in the discovery document of %s", k.Issuer)
+	}
+	var raw json.RawMessage
+	if err := k.get(ctx, discovery.JWKSURI, &raw); err != nil {
+		return nil, err
+	}
+	keys, err := ParseKeySet(raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the keys of %s: %w", k.Issuer, err)
+	}
+	return keys, nil
+}
+
+func (k *IssuerKeys) get(ctx context.Context, url string, v interface{}) error {
+	client := k.Client
+	if client == nil {
+		client = &http.Client{Timeout: fetchTimeout}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("fetching %s: %s", url, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,304 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package limiter
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const pruneInterval = time.Minute
+
+// Limit is a token bucket of Rate requests per second with Burst capacity,
+// plus a cap on the streams open at the same time. Zero disables a check.
+type Limit struct {
+	Rate       float64
+	Burst      int
+	MaxStreams int
+}
+
+func (l Limit) enabled() bool {
+	return l.Rate > 0 || l.MaxStreams > 0
+}
+
+func (l Limit) burst() float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.Rate))
+}
+
+type Config struct {
+	Global    Limit
+	Namespace Limit
+	APIKey    Limit
+}
+
+// LimitError is returned by Acquire when a request exceeds one of the limits.
+type LimitError struct {
+	Scope      string
+	Reason     string
+	RetryAfter time.Duration
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%s limit exceeded: %s", e.Scope, e.Reason)
+}
+
+type bucket struct {
+	limit  Limit
+	tokens float64
+	last   time.Time
+	active int
+}
+
+func newBucket(limit Limit, now time.Time) *bucket {
+	return &bucket{limit: limit, tokens: limit.burst(), last: now}
+}
+
+func (b *bucket) refill(now time.Time) {
+	if b.limit.Rate <= 0 {
+		return
+	}
+	elapsed := now.Sub(b.last).Seconds()
+	b.last = now
+	b.tokens = math.Min(b.limit.burst(), b.tokens+elapsed*b.limit.Rate)
+}
+
+func (b *bucket) check(scope string, now time.Time) *LimitError {
+	b.refill(now)
+	if b.limit.MaxStreams > 0 && b.active >= b.limit.MaxStreams {
+		return &LimitError{
+			Scope:      scope,
+			Reason:     fmt.Sprintf("too many concurrent streams (max %d)", b.limit.MaxStreams),
+			RetryAfter: time.Second,
+		}
+	}
+	if b.limit.Rate > 0 && b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / b.limit.Rate * float64(time.Second))
+		return &LimitError{
+			Scope:      scope,
+			Reason:     fmt.Sprintf("rate limit of %g requests per second", b.limit.Rate),
+			RetryAfter: wait,
+		}
+	}
+	return nil
+}
+
+func (b *bucket) update(limit Limit, now time.Time) {
+	b.refill(now)
+	if b.limit.Rate <= 0 {
+		// the bucket was not in use, start it full
+		b.tokens = limit.burst()
+	}
+	b.limit = limit
+	b.last = now
+	b.tokens = math.Min(b.tokens, limit.burst())
+}
+
+func (b *bucket) idle() bool {
+	return b.active == 0 && (b.limit.Rate <= 0 || b.tokens >= b.limit.burst())
+}
+
+type Limiter struct {
+	mu         sync.Mutex
+	cfg        Config
+	global     *bucket
+	namespaces map[string]*bucket
+	apiKeys    map[string]*bucket
+	lastPrune  time.Time
+	now        func() time.Time
+}
+
+func New(cfg Config) *Limiter {
+	l := &Limiter{
+		cfg:        cfg,
+		namespaces: make(map[string]*bucket),
+		apiKeys:    make(map[string]*bucket),
+		now:        time.Now,
+	}
+	l.global = newBucket(cfg.Global, l.now())
+	l.lastPrune = l.now()
+	return l
+}
+
+// SetConfig changes the limits in place. Open streams keep their slots and
+// count against the new caps.
+func (l *Limiter) SetConfig(cfg Config) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.cfg = cfg
+	l.global.update(cfg.Global, now)
+	for _, b := range l.namespaces {
+		b.update(cfg.Namespace, now)
+	}
+	for _, b := range l.apiKeys {
+		b.update(cfg.APIKey, now)
+	}
+}
+
+// Acquire takes a token and a stream slot in the global, namespace and API key
+// scopes. Nothing is taken unless all the scopes allow the request. The
+// returned function gives the stream slots back and is safe to call twice.
+func (l *Limiter) Acquire(namespace string, apiKey string) (func(), error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastPrune) > pruneInterval {
+		l.prune(now)
+	}
+
+	buckets := []*bucket{}
+	scopes := []string{}
+	if l.cfg.Global.enabled() {
+		buckets = append(buckets, l.global)
+		scopes = append(scopes, "global")
+	}
+	if l.cfg.Namespace.enabled() && namespace != "" {
+		buckets = append(buckets, l.lookup(l.namespaces, namespace, l.cfg.Namespace, now))
+		scopes = append(scopes, "namespace")
+	}
+	if l.cfg.APIKey.enabled() && apiKey != "" {
+		buckets = append(buckets, l.lookup(l.apiKeys, apiKey, l.cfg.APIKey, now))
+		scopes = append(scopes, "api key")
+	}
+
+	var rejected *LimitError
+	for i, b := range buckets {
+		if err := b.check(scopes[i], now); err != nil {
+			if rejected == nil || err.RetryAfter > rejected.RetryAfter {
+				rejected = err
+			}
+		}
+	}
+	if rejected != nil {
+		return nil, rejected
+	}
+
+	for _, b := range buckets {
+		if b.limit.Rate > 0 {
+			b.tokens--
+		}
+		b.active++
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			for _, b := range buckets {
+				b.active--
+			}
+		})
+	}, nil
+}
+
+func (l *Limiter) lookup(m map[string]*bucket, key string, limit Limit, now time.Time) *bucket {
+	b, ok := m[key]
+	if !ok {
+		b = newBucket(limit, now)
+		m[key] = b
+	}
+	return b
+}
+
+// prune drops the per-namespace and per-key buckets that have no open stream
+// and a full bucket, so that they do not accumulate forever.
+func (l *Limiter) prune(now time.Time) {
+	for _, m := range []map[string]*bucket{l.namespaces, l.apiKeys} {
+		for key, b := range m {
+			b.refill(now)
+			if b.idle() {
+				delete(m, key)
+			}
+		}
+	}
+	l.lastPrune = now
+}
+
+type ScopeUsage struct {
+	Key           string  `json:"key,omitempty"`
+	ActiveStreams int     `json:"active_streams"`
+	MaxStreams    int     `json:"max_streams,omitempty"`
+	Tokens        float64 `json:"tokens,omitempty"`
+	Rate          float64 `json:"rate,omitempty"`
+	Burst         int     `json:"burst,omitempty"`
+}
+
+type Usage struct {
+	Global     ScopeUsage   `json:"global"`
+	Namespaces []ScopeUsage `json:"namespaces"`
+	APIKeys    []ScopeUsage `json:"api_keys"`
+}
+
+// Usage reports the current state of every bucket. API keys are masked.
+func (l *Limiter) Usage() Usage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	usage := Usage{
+		Global:     l.global.usage("", now),
+		Namespaces: []ScopeUsage{},
+		APIKeys:    []ScopeUsage{},
+	}
+	for ns, b := range l.namespaces {
+		usage.Namespaces = append(usage.Namespaces, b.usage(ns, now))
+	}
+	for key, b := range l.apiKeys {
+		usage.APIKeys = append(usage.APIKeys, b.usage(MaskKey(key), now))
+	}
+	sort.Slice(usage.Namespaces, func(i, j int) bool { return usage.Namespaces[i].Key < usage.Namespaces[j].Key })
+	sort.Slice(usage.APIKeys, func(i, j int) bool { return usage.APIKeys[i].Key < usage.APIKeys[j].Key })
+	return usage
+}
+
+func (b *bucket) usage(key string, now time.Time) ScopeUsage {
+	b.refill(now)
+	u := ScopeUsage{
+		Key:           key,
+		ActiveStreams: b.active,
+		MaxStreams:    b.limit.MaxStreams,
+		Rate:          b.limit.Rate,
+	}
+	if b.limit.Rate > 0 {
+		u.Tokens = math.Floor(b.tokens*100) / 100
+		u.Burst = int(b.limit.burst())
+	}
+	return u
+}
+
+// MaskKey hides the secret part of an OpenWhisk key (uuid:key), keeping
+// only the uuid, or the first characters of keys in any other format.
+func MaskKey(key string) string {
+	if uuid, _, found := strings.Cut(key, ":"); found {
+		return uuid + ":***"
+	}
+	if len(key) > 8 {
+		return key[:8] + "***"
+	}
+	return "***"
+}
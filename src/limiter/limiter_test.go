@@ -0,0 +1,159 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package limiter
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestLimiter(cfg Config) (*Limiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l := New(cfg)
+	l.now = func() time.Time { return now }
+	l.global = newBucket(cfg.Global, now)
+	l.lastPrune = now
+	return l, &now
+}
+
+func TestAcquireRateLimit(t *testing.T) {
+	l, now := newTestLimiter(Config{Namespace: Limit{Rate: 1, Burst: 2}})
+
+	for i := 0; i < 2; i++ {
+		release, err := l.Acquire("ns1", "")
+		require.NoError(t, err)
+		release()
+	}
+
+	_, err := l.Acquire("ns1", "")
+	var limitErr *LimitError
+	require.True(t, errors.As(err, &limitErr))
+	require.Equal(t, "namespace", limitErr.Scope)
+	require.Equal(t, time.Second, limitErr.RetryAfter)
+
+	// other namespaces have their own bucket
+	_, err = l.Acquire("ns2", "")
+	require.NoError(t, err)
+
+	*now = now.Add(time.Second)
+	_, err = l.Acquire("ns1", "")
+	require.NoError(t, err)
+}
+
+func TestAcquireMaxStreams(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Global: Limit{MaxStreams: 3},
+		APIKey: Limit{MaxStreams: 1},
+	})
+
+	release, err := l.Acquire("ns", "uuid1:key")
+	require.NoError(t, err)
+
+	_, err = l.Acquire("ns", "uuid1:key")
+	require.ErrorContains(t, err, "api key limit exceeded")
+
+	_, err = l.Acquire("ns", "uuid2:key")
+	require.NoError(t, err)
+	_, err = l.Acquire("ns", "uuid3:key")
+	require.NoError(t, err)
+
+	_, err = l.Acquire("ns", "uuid4:key")
+	require.ErrorContains(t, err, "global limit exceeded")
+
+	release()
+	release()
+	_, err = l.Acquire("ns", "uuid1:key")
+	require.NoError(t, err)
+	require.Equal(t, 3, l.Usage().Global.ActiveStreams)
+}
+
+func TestAcquireRejectedTakesNothing(t *testing.T) {
+	l, _ := newTestLimiter(Config{
+		Global:    Limit{Rate: 10, Burst: 10},
+		Namespace: Limit{MaxStreams: 1},
+	})
+
+	_, err := l.Acquire("ns", "")
+	require.NoError(t, err)
+	_, err = l.Acquire("ns", "")
+	require.Error(t, err)
+
+	usage := l.Usage()
+	require.Equal(t, 1, usage.Global.ActiveStreams)
+	require.Equal(t, 9.0, usage.Global.Tokens)
+}
+
+func TestUsageAndPrune(t *testing.T) {
+	l, now := newTestLimiter(Config{
+		Namespace: Limit{Rate: 1, MaxStreams: 5},
+		APIKey:    Limit{MaxStreams: 5},
+	})
+
+	release, err := l.Acquire("ns1", "23bc46b1-71f6-4ed5-8c54-816aa4f8c502:secret")
+	require.NoError(t, err)
+	_, err = l.Acquire("ns2", "")
+	require.NoError(t, err)
+
+	usage := l.Usage()
+	require.Len(t, usage.Namespaces, 2)
+	require.Equal(t, "ns1", usage.Namespaces[0].Key)
+	require.Len(t, usage.APIKeys, 1)
+	require.Equal(t, "23bc46b1-71f6-4ed5-8c54-816aa4f8c502:***", usage.APIKeys[0].Key)
+
+	release()
+	*now = now.Add(2 * pruneInterval)
+	_, err = l.Acquire("", "")
+	require.NoError(t, err)
+
+	usage = l.Usage()
+	require.Len(t, usage.Namespaces, 1)
+	require.Equal(t, "ns2", usage.Namespaces[0].Key)
+	require.Empty(t, usage.APIKeys)
+}
+
+func TestSetConfig(t *testing.T) {
+	l, _ := newTestLimiter(Config{Namespace: Limit{MaxStreams: 2}})
+
+	_, err := l.Acquire("ns", "")
+	require.NoError(t, err)
+	_, err = l.Acquire("ns", "")
+	require.NoError(t, err)
+
+	l.SetConfig(Config{Namespace: Limit{Rate: 1, Burst: 5, MaxStreams: 3}})
+	usage := l.Usage()
+	require.Equal(t, 2, usage.Namespaces[0].ActiveStreams)
+	require.Equal(t, 3, usage.Namespaces[0].MaxStreams)
+	require.Equal(t, 5.0, usage.Namespaces[0].Tokens)
+
+	_, err = l.Acquire("ns", "")
+	require.NoError(t, err)
+	_, err = l.Acquire("ns", "")
+	require.ErrorContains(t, err, "too many concurrent streams (max 3)")
+
+	l.SetConfig(Config{Namespace: Limit{Rate: 1, Burst: 2, MaxStreams: 10}})
+	require.Equal(t, 2.0, l.Usage().Namespaces[0].Tokens)
+}
+
+func TestMaskKey(t *testing.T) {
+	require.Equal(t, "uuid:***", MaskKey("uuid:secret"))
+	require.Equal(t, "abcdefgh***", MaskKey("abcdefghijkl"))
+	require.Equal(t, "***", MaskKey("short"))
+}
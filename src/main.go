@@ -17,18 +17,79 @@
 
 package main
 
-import "os"
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/apache/openserverless-streaming-proxy/config"
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/health"
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+)
 
 func main() {
-	owApihost := os.Getenv("OW_APIHOST")
-	if owApihost == "" {
-		panic("OW_APIHOST is not set")
+	loader, err := config.NewLoader(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	cfg, err := loader.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	if loader.PrintConfig {
+		out, err := cfg.Redacted().YAML()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Stdout.Write(out)
+		return
+	}
+
+	registry := streams.NewRegistry()
+	if cfg.Dev.Enabled {
+		if err := startDevServer(cfg.Dev, registry); err != nil {
+			fmt.Fprintln(os.Stderr, "Error starting the OpenWhisk emulator:", err)
+			os.Exit(1)
+		}
+	}
+
+	polls := handlers.NewPollSessions()
+	streamLimiter := limiter.New(cfg.Limits.Limiter())
+	checker := health.NewChecker(cfg.OpenWhiskHost(), cfg.BindAddr())
+	rec := recorder.New()
+
+	if cfg.Admin.Port != 0 {
+		go startAdminServer(cfg.Admin, registry, streamLimiter, rec)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	rl, err := newReloader(loader, cfg, registry, polls, streamLimiter, checker, rec)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
+	go rl.watch(ctx)
 
-	streamerAddr := os.Getenv("STREAMER_ADDR")
-	if streamerAddr == "" {
-		panic("STREAMER_ADDR is not set")
+	if cfg.GRPC.Port != 0 {
+		go startGRPCServer(ctx, rl)
 	}
 
-	startHTTPServer(streamerAddr, owApihost)
+	startHTTPServer(ctx, rl, checker)
 }
@@ -0,0 +1,409 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package recorder records the streams to a directory, a JSONL file each, to
+// replay them when debugging the actions.
+package recorder
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrNotFound is returned for the streams without a recording.
+var ErrNotFound = errors.New("recording not found")
+
+const fileSuffix = ".jsonl"
+
+// Options are where the recordings are kept and for how long. The oldest
+// recordings are removed beyond MaxStreams recordings, MaxSize bytes in
+// total, or once older than MaxAge. 0 means no limit. An empty Dir disables
+// the recording.
+type Options struct {
+	Dir        string
+	MaxStreams int
+	MaxSize    int64
+	MaxAge     time.Duration
+}
+
+// Recorder writes the recordings to Options.Dir. It keeps the size of every
+// recording in memory, to apply the retention limits as they are written:
+// the oldest finished recordings are removed to make room, and a recording
+// that would still go past the limits is stopped, or not started.
+type Recorder struct {
+	mu         sync.Mutex
+	opts       Options
+	recordings map[string]*entry
+	total      int64
+	now        func() time.Time
+}
+
+// entry is a recording of Options.Dir, being written when active.
+type entry struct {
+	id      string
+	size    int64
+	modTime time.Time
+	active  bool
+}
+
+func New() *Recorder {
+	return &Recorder{recordings: make(map[string]*entry), now: time.Now}
+}
+
+// SetOptions applies opts to the new recordings, creating the directory and
+// indexing the recordings it has when it changes.
+func (rc *Recorder) SetOptions(opts Options) error {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if opts.Dir != rc.opts.Dir {
+		recordings := make(map[string]*entry)
+		var total int64
+		if opts.Dir != "" {
+			if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+				return fmt.Errorf("creating the recordings dir: %w", err)
+			}
+			files, err := os.ReadDir(opts.Dir)
+			if err != nil {
+				return fmt.Errorf("listing the recordings: %w", err)
+			}
+			for _, file := range files {
+				id, ok := strings.CutSuffix(file.Name(), fileSuffix)
+				info, err := file.Info()
+				if !ok || !validID(id) || err != nil || !info.Mode().IsRegular() {
+					continue
+				}
+				recordings[id] = &entry{id: id, size: info.Size(), modTime: info.ModTime()}
+				total += info.Size()
+			}
+		}
+		rc.recordings, rc.total = recordings, total
+	}
+	rc.opts = opts
+	rc.prune(0)
+	return nil
+}
+
+// Enabled tells whether the streams are recorded.
+func (rc *Recorder) Enabled() bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.opts.Dir != ""
+}
+
+// Metadata describes the recorded stream, in its start record.
+type Metadata struct {
+	ID        string
+	Kind      string
+	Namespace string
+	Action    string
+	ClientIP  string
+	StartTime time.Time
+}
+
+// Record is a line of a recording. The first one is the start record, with
+// the metadata, followed by the data records and, unless the client went
+// away, an end or error record. Elapsed is the time since the start.
+type Record struct {
+	Type      string     `json:"type"`
+	Elapsed   float64    `json:"elapsed_ms"`
+	ID        string     `json:"id,omitempty"`
+	Kind      string     `json:"kind,omitempty"`
+	Namespace string     `json:"namespace,omitempty"`
+	Action    string     `json:"action,omitempty"`
+	ClientIP  string     `json:"client_ip,omitempty"`
+	StartTime *time.Time `json:"start_time,omitempty"`
+	Seq       int        `json:"seq,omitempty"`
+	// Data is the output of the action, or the summary of the end record,
+	// base64 encoded when it is not text.
+	Data         string `json:"data,omitempty"`
+	Encoding     string `json:"encoding,omitempty"`
+	Bytes        *int64 `json:"bytes,omitempty"`
+	ActivationID string `json:"activation_id,omitempty"`
+	Code         string `json:"code,omitempty"`
+	Message      string `json:"message,omitempty"`
+	Status       int    `json:"status,omitempty"`
+}
+
+// ElapsedTime is the time since the start of the stream.
+func (r Record) ElapsedTime() time.Duration {
+	return time.Duration(r.Elapsed * float64(time.Millisecond))
+}
+
+// Payload decodes Data.
+func (r Record) Payload() ([]byte, error) {
+	if r.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(r.Data)
+	}
+	return []byte(r.Data), nil
+}
+
+func (r *Record) setPayload(data []byte) {
+	if utf8.Valid(data) {
+		r.Data = string(data)
+		return
+	}
+	r.Data, r.Encoding = base64.StdEncoding.EncodeToString(data), "base64"
+}
+
+// Recording is the recording of a stream in progress. Failing to write it,
+// or going past the limits, is logged and ends the recording, never the
+// stream.
+type Recording struct {
+	mu    sync.Mutex
+	rc    *Recorder
+	entry *entry
+	file  *os.File
+	start time.Time
+}
+
+// Start makes room for a new recording and starts it with meta.
+func (rc *Recorder) Start(meta Metadata) (*Recording, error) {
+	if !validID(meta.ID) {
+		return nil, fmt.Errorf("invalid stream id %q", meta.ID)
+	}
+	rec, err := rc.create(meta)
+	if err != nil {
+		return nil, err
+	}
+	rec.write(Record{
+		Type:      "start",
+		ID:        meta.ID,
+		Kind:      meta.Kind,
+		Namespace: meta.Namespace,
+		Action:    meta.Action,
+		ClientIP:  meta.ClientIP,
+		StartTime: &meta.StartTime,
+	})
+	return rec, nil
+}
+
+func (rc *Recorder) create(meta Metadata) (*Recording, error) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.opts.Dir == "" {
+		return nil, errors.New("recording disabled")
+	}
+	if !rc.prune(1) {
+		return nil, errors.New("too many recordings in progress")
+	}
+	file, err := os.OpenFile(rc.path(meta.ID), os.O_CREATE|os.O_WRONLY|os.O_EXCL, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	e := &entry{id: meta.ID, modTime: rc.now(), active: true}
+	rc.recordings[meta.ID] = e
+	return &Recording{rc: rc, entry: e, file: file, start: meta.StartTime}, nil
+}
+
+// Data records a message of the action.
+func (rec *Recording) Data(seq int, data []byte) {
+	record := Record{Type: "data", Seq: seq}
+	record.setPayload(data)
+	rec.write(record)
+}
+
+// End records the end of the stream, with the summary of the action.
+func (rec *Recording) End(seq int, relayed int64, activationID string, summary []byte) {
+	record := Record{Type: "end", Seq: seq, Bytes: &relayed, ActivationID: activationID}
+	record.setPayload(summary)
+	rec.write(record)
+}
+
+// Error records the failure that ended the stream, with the status it was
+// answered with when nothing was written yet.
+func (rec *Recording) Error(code string, message string, status int) {
+	rec.write(Record{Type: "error", Code: code, Message: message, Status: status})
+}
+
+// Close ends the recording.
+func (rec *Recording) Close() error {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return rec.close()
+}
+
+func (rec *Recording) close() error {
+	if rec.file == nil {
+		return nil
+	}
+	err := rec.file.Close()
+	rec.file = nil
+	rec.rc.finished(rec.entry)
+	return err
+}
+
+func (rec *Recording) write(record Record) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.file == nil {
+		return
+	}
+	record.Elapsed = float64(rec.rc.now().Sub(rec.start).Microseconds()) / 1000
+	line, err := json.Marshal(record)
+	if err == nil && !rec.rc.reserve(rec.entry, int64(len(line)+1)) {
+		err = errors.New("recordings over the max size")
+	}
+	if err == nil {
+		_, err = rec.file.Write(append(line, '\n'))
+	}
+	if err != nil {
+		log.Printf("Error recording to %s, recording stopped: %s", rec.file.Name(), err)
+		rec.close()
+	}
+}
+
+// reserve accounts for n more bytes of e, removing the oldest finished
+// recordings to stay within MaxSize. It tells whether they fit.
+func (rc *Recorder) reserve(e *entry, n int64) bool {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if rc.recordings[e.id] != e {
+		// recorded to a directory no longer in use
+		return true
+	}
+	if rc.opts.MaxSize > 0 && rc.total+n > rc.opts.MaxSize {
+		rc.evict(func(count int, total int64) bool { return total+n > rc.opts.MaxSize })
+		if rc.total+n > rc.opts.MaxSize {
+			return false
+		}
+	}
+	e.size += n
+	rc.total += n
+	return true
+}
+
+// finished marks e as no longer written.
+func (rc *Recorder) finished(e *entry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	e.active = false
+	e.modTime = rc.now()
+}
+
+// Load reads the recording of the stream id.
+func (rc *Recorder) Load(id string) ([]Record, error) {
+	rc.mu.Lock()
+	path := rc.path(id)
+	disabled := rc.opts.Dir == ""
+	rc.mu.Unlock()
+	if disabled || !validID(id) {
+		return nil, ErrNotFound
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			// the last line is cut when the streamer stopped while writing it
+			break
+		}
+		records = append(records, record)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading recording %s: %w", id, err)
+	}
+	if len(records) == 0 || records[0].Type != "start" {
+		return nil, fmt.Errorf("recording %s has no start record", id)
+	}
+	return records, nil
+}
+
+func (rc *Recorder) path(id string) string {
+	return filepath.Join(rc.opts.Dir, id+fileSuffix)
+}
+
+// prune removes the expired recordings, and the oldest ones beyond the
+// limits, leaving room for n new ones. It tells whether there is room.
+func (rc *Recorder) prune(n int) bool {
+	if rc.opts.MaxAge > 0 {
+		now := rc.now()
+		for _, e := range rc.recordings {
+			if !e.active && now.Sub(e.modTime) > rc.opts.MaxAge {
+				rc.remove(e)
+			}
+		}
+	}
+	over := func(count int, total int64) bool {
+		return (rc.opts.MaxStreams > 0 && count+n > rc.opts.MaxStreams) ||
+			(rc.opts.MaxSize > 0 && total > rc.opts.MaxSize)
+	}
+	rc.evict(over)
+	return !over(len(rc.recordings), rc.total)
+}
+
+// evict removes the oldest finished recordings while over the limits.
+func (rc *Recorder) evict(over func(count int, total int64) bool) {
+	if !over(len(rc.recordings), rc.total) {
+		return
+	}
+	finished := make([]*entry, 0, len(rc.recordings))
+	for _, e := range rc.recordings {
+		if !e.active {
+			finished = append(finished, e)
+		}
+	}
+	sort.Slice(finished, func(i, j int) bool { return finished[i].modTime.Before(finished[j].modTime) })
+	for _, e := range finished {
+		if !over(len(rc.recordings), rc.total) {
+			return
+		}
+		rc.remove(e)
+	}
+}
+
+func (rc *Recorder) remove(e *entry) {
+	if err := os.Remove(rc.path(e.id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Println("Error removing recording:", err)
+		return
+	}
+	delete(rc.recordings, e.id)
+	rc.total -= e.size
+}
+
+// validID accepts the hex ids of the streams, which are safe as file names.
+func validID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,159 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	rc := New()
+	require.False(t, rc.Enabled())
+	_, err := rc.Start(Metadata{ID: "abc123"})
+	require.Error(t, err)
+	_, err = rc.Load("abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	require.NoError(t, rc.SetOptions(Options{Dir: filepath.Join(t.TempDir(), "recordings")}))
+	require.True(t, rc.Enabled())
+
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	now := start
+	rc.now = func() time.Time { return now }
+
+	rec, err := rc.Start(Metadata{ID: "abc123", Kind: "action", Namespace: "ns", Action: "pkg/hello", ClientIP: "10.0.0.1", StartTime: start})
+	require.NoError(t, err)
+	now = now.Add(1500 * time.Microsecond)
+	rec.Data(1, []byte("hello"))
+	now = now.Add(time.Second)
+	rec.Data(2, []byte{0xff, 0xfe})
+	rec.End(3, 7, "act-1", []byte(`{"tokens": 2}`))
+	require.NoError(t, rec.Close())
+	// writing after the end is ignored
+	rec.Data(4, []byte("late"))
+
+	records, err := rc.Load("abc123")
+	require.NoError(t, err)
+	require.Len(t, records, 4)
+
+	require.Equal(t, "start", records[0].Type)
+	require.Equal(t, "pkg/hello", records[0].Action)
+	require.Equal(t, start, *records[0].StartTime)
+
+	require.Equal(t, 1.5, records[1].Elapsed)
+	require.Equal(t, 1500*time.Microsecond, records[1].ElapsedTime())
+	payload, err := records[1].Payload()
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(payload))
+
+	require.Equal(t, "base64", records[2].Encoding)
+	payload, err = records[2].Payload()
+	require.NoError(t, err)
+	require.Equal(t, []byte{0xff, 0xfe}, payload)
+
+	require.Equal(t, "end", records[3].Type)
+	require.Equal(t, int64(7), *records[3].Bytes)
+	require.Equal(t, "act-1", records[3].ActivationID)
+
+	_, err = rc.Load("missing")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = rc.Load("../abc123")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = rc.Start(Metadata{ID: "../escape"})
+	require.Error(t, err)
+}
+
+func TestRetention(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		expected []string
+	}{
+		{"no limits", Options{}, []string{"a1", "a2", "a3", "a4", "b0"}},
+		{"max streams", Options{MaxStreams: 3}, []string{"a3", "a4", "b0"}},
+		{"max size", Options{MaxSize: 350}, []string{"a3", "a4", "b0"}},
+		{"max age", Options{MaxAge: 90 * time.Minute}, []string{"a4", "b0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Dir = t.TempDir()
+
+			// a1 is the oldest, recorded 4 hours ago, a4 the newest
+			now := time.Now()
+			for i, id := range []string{"a1", "a2", "a3", "a4"} {
+				path := filepath.Join(tt.opts.Dir, id+fileSuffix)
+				require.NoError(t, os.WriteFile(path, make([]byte, 100), 0o600))
+				modTime := now.Add(time.Duration(i-4) * time.Hour)
+				require.NoError(t, os.Chtimes(path, modTime, modTime))
+			}
+			rc := New()
+			require.NoError(t, rc.SetOptions(tt.opts))
+			rec, err := rc.Start(Metadata{ID: "b0", StartTime: now})
+			require.NoError(t, err)
+			require.NoError(t, rec.Close())
+
+			require.Equal(t, tt.expected, recordingIDs(t, tt.opts.Dir))
+		})
+	}
+}
+
+func TestLimitsWhileRecording(t *testing.T) {
+	dir := t.TempDir()
+	rc := New()
+	require.NoError(t, rc.SetOptions(Options{Dir: dir, MaxStreams: 1, MaxSize: 300}))
+
+	rec, err := rc.Start(Metadata{ID: "a1", StartTime: time.Now()})
+	require.NoError(t, err)
+	// the recordings in progress are not removed to make room
+	_, err = rc.Start(Metadata{ID: "a2", StartTime: time.Now()})
+	require.Error(t, err)
+
+	// a recording going past the max size is stopped
+	rec.Data(1, []byte("hello"))
+	rec.Data(2, make([]byte, 300))
+	rec.Data(3, []byte("world"))
+	records, err := rc.Load("a1")
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	info, err := os.Stat(filepath.Join(dir, "a1"+fileSuffix))
+	require.NoError(t, err)
+	require.LessOrEqual(t, info.Size(), int64(300))
+
+	// once finished, it makes room for the next one
+	require.NoError(t, rec.Close())
+	rec, err = rc.Start(Metadata{ID: "a2", StartTime: time.Now()})
+	require.NoError(t, err)
+	require.NoError(t, rec.Close())
+	require.Equal(t, []string{"a2"}, recordingIDs(t, dir))
+}
+
+func recordingIDs(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	ids := []string{}
+	for _, entry := range entries {
+		ids = append(ids, entry.Name()[:2])
+	}
+	return ids
+}
@@ -0,0 +1,200 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/config"
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/health"
+	"github.com/apache/openserverless-streaming-proxy/jwt"
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+)
+
+const configPollInterval = 2 * time.Second
+
+// reloader serves the public routes, swapping them atomically when the
+// configuration is reloaded. Requests already in progress, and so the open
+// streams, complete on the handler they started with.
+type reloader struct {
+	loader        *config.Loader
+	registry      *streams.Registry
+	polls         *handlers.PollSessions
+	streamLimiter *limiter.Limiter
+	checker       *health.Checker
+	recorder      *recorder.Recorder
+	grpcServer    *handlers.GRPCServer
+	issuerKeys    *jwt.IssuerKeys
+
+	mu      sync.Mutex
+	config  atomic.Pointer[config.Config]
+	handler atomic.Pointer[http.Handler]
+}
+
+func newReloader(loader *config.Loader, cfg *config.Config, registry *streams.Registry, polls *handlers.PollSessions, streamLimiter *limiter.Limiter, checker *health.Checker, rec *recorder.Recorder) (*reloader, error) {
+	rl := &reloader{
+		loader:        loader,
+		registry:      registry,
+		polls:         polls,
+		streamLimiter: streamLimiter,
+		checker:       checker,
+		recorder:      rec,
+		grpcServer:    handlers.NewGRPCServer(handlers.StreamConfig{}, streamLimiter),
+	}
+	if err := rl.apply(cfg); err != nil {
+		return nil, err
+	}
+	return rl, nil
+}
+
+func (rl *reloader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	(*rl.handler.Load()).ServeHTTP(w, r)
+}
+
+func (rl *reloader) Config() *config.Config {
+	return rl.config.Load()
+}
+
+func (rl *reloader) apply(cfg *config.Config) error {
+	tcpOptions, err := newTCPOptions(cfg.Stream)
+	if err != nil {
+		return err
+	}
+	issuerKeys := rl.keysOf(cfg.Auth.JWT)
+	auth, webAuth, err := newAuthenticators(cfg, issuerKeys)
+	if err != nil {
+		return err
+	}
+	var rec *recorder.Recorder
+	if cfg.Record.Enabled() {
+		rec = rl.recorder
+	}
+	streamConfig := newStreamConfig(cfg, tcpOptions, auth, webAuth, rl.streamLimiter, rec, rl.registry, rl.polls)
+	handler, err := newRouter(cfg, streamConfig, rl.checker)
+	if err != nil {
+		return err
+	}
+	if err := rl.recorder.SetOptions(cfg.Record.Options()); err != nil {
+		return err
+	}
+	rl.streamLimiter.SetConfig(cfg.Limits.Limiter())
+	rl.checker.SetTargets(cfg.OpenWhiskHost(), cfg.BindAddr(), tcpOptions)
+	rl.grpcServer.SetConfig(streamConfig)
+	rl.issuerKeys = issuerKeys
+	rl.handler.Store(&handler)
+	rl.config.Store(cfg)
+	return nil
+}
+
+// keysOf returns the keys of the issuer of cfg, keeping those fetched
+// already if the issuer did not change.
+func (rl *reloader) keysOf(cfg config.JWTConfig) *jwt.IssuerKeys {
+	if rl.issuerKeys != nil && rl.issuerKeys.Issuer == cfg.Issuer && rl.issuerKeys.TTL == cfg.KeysTTL.Duration() {
+		return rl.issuerKeys
+	}
+	return &jwt.IssuerKeys{Issuer: cfg.Issuer, TTL: cfg.KeysTTL.Duration()}
+}
+
+// reload loads the configuration again, keeping the current one if the new
+// one is not valid.
+func (rl *reloader) reload() {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	cfg, err := rl.loader.Load()
+	if err != nil {
+		log.Printf("Configuration not reloaded:\n%s", err)
+		return
+	}
+
+	changes, err := config.Diff(rl.Config(), cfg)
+	if err != nil {
+		log.Println("Configuration not reloaded:", err)
+		return
+	}
+	if len(changes) == 0 {
+		log.Println("Configuration reloaded, nothing changed")
+		return
+	}
+	if err := rl.apply(cfg); err != nil {
+		log.Println("Configuration not reloaded:", err)
+		return
+	}
+	for _, change := range changes {
+		if change.RequiresRestart() {
+			log.Printf("Configuration changed: %s (requires a restart)", change)
+		} else {
+			log.Printf("Configuration changed: %s", change)
+		}
+	}
+	log.Printf("Configuration reloaded, %d active streams left untouched", len(rl.registry.List()))
+}
+
+// watch reloads the configuration on SIGHUP, and when the config file
+// changes, until ctx is done.
+func (rl *reloader) watch(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+	lastMod := rl.fileModTime()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("SIGHUP received, reloading configuration")
+			rl.reload()
+		case <-ticker.C:
+			if rl.loader.Path == "" {
+				continue
+			}
+			modTime := rl.fileModTime()
+			if !modTime.Equal(lastMod) {
+				lastMod = modTime
+				log.Println("Config file changed, reloading configuration")
+				rl.reload()
+			}
+		}
+	}
+}
+
+func (rl *reloader) fileModTime() time.Time {
+	if rl.loader.Path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(rl.loader.Path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
@@ -0,0 +1,140 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/config"
+	"github.com/apache/openserverless-streaming-proxy/handlers"
+	"github.com/apache/openserverless-streaming-proxy/health"
+	"github.com/apache/openserverless-streaming-proxy/limiter"
+	"github.com/apache/openserverless-streaming-proxy/recorder"
+	"github.com/apache/openserverless-streaming-proxy/streams"
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadConfig = `apihost: http://127.0.0.1:1
+streamer_addr: 127.0.0.1
+`
+
+// newTestReloader serves the configuration of a temp file, written again
+// with write.
+func newTestReloader(t *testing.T) (*reloader, func(string)) {
+	path := filepath.Join(t.TempDir(), "streamer.yaml")
+	write := func(content string) {
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	write(reloadConfig)
+
+	loader, err := config.NewLoader([]string{"--config", path})
+	require.NoError(t, err)
+	cfg, err := loader.Load()
+	require.NoError(t, err)
+	rl, err := newReloader(loader, cfg, streams.NewRegistry(), handlers.NewPollSessions(),
+		limiter.New(cfg.Limits.Limiter()), health.NewChecker(cfg.OpenWhiskHost(), cfg.BindAddr()), recorder.New())
+	require.NoError(t, err)
+	return rl, write
+}
+
+// serve returns the status of a request to the handler of rl.
+func serve(rl *reloader, method string, path string) int {
+	w := httptest.NewRecorder()
+	rl.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w.Code
+}
+
+func TestReload(t *testing.T) {
+	rl, write := newTestReloader(t)
+	// the actions need a key
+	require.Equal(t, http.StatusUnauthorized, serve(rl, "POST", "/action/ns/hello"))
+
+	// the handler of a valid configuration replaces the current one
+	current := rl.Config()
+	write(reloadConfig + "routes:\n  action: false\n")
+	rl.reload()
+	require.NotSame(t, current, rl.Config())
+	require.False(t, rl.Config().Routes.Action)
+	require.Equal(t, http.StatusNotFound, serve(rl, "POST", "/action/ns/hello"))
+
+	// an invalid configuration keeps the current one
+	current = rl.Config()
+	write(reloadConfig + "auth:\n  mode: oauth\n")
+	rl.reload()
+	require.Same(t, current, rl.Config())
+	require.Equal(t, http.StatusNotFound, serve(rl, "POST", "/action/ns/hello"))
+
+	// as does a valid one that can not be applied
+	write(reloadConfig + "routes:\n  table:\n    - {path: /chat, namespace: ns, action: chat, schema: /missing.yaml}\n")
+	rl.reload()
+	require.Same(t, current, rl.Config())
+	require.Equal(t, http.StatusNotFound, serve(rl, "POST", "/chat"))
+}
+
+func TestReloadKeepsStreams(t *testing.T) {
+	rl, write := newTestReloader(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sock, err := tcp.SetupTcpServer(ctx, "127.0.0.1", tcp.Options{})
+	require.NoError(t, err)
+	stream := streams.NewStream("action", "ns", "hello", "127.0.0.1", sock, cancel)
+	rl.registry.Add(stream)
+
+	write(reloadConfig + "stream:\n  idle_timeout: 1m\n")
+	rl.reload()
+	require.Equal(t, time.Minute, rl.Config().Stream.IdleTimeout.Duration())
+
+	_, ok := rl.registry.Get(stream.ID)
+	require.True(t, ok)
+	require.NoError(t, ctx.Err())
+	select {
+	case <-stream.Done():
+		require.Fail(t, "Stream ended by the reload")
+	default:
+	}
+}
+
+func TestKeysOf(t *testing.T) {
+	rl := &reloader{}
+	jwtConfig := config.JWTConfig{Issuer: "https://id.example.com", KeysTTL: config.Duration(time.Hour)}
+
+	keys := rl.keysOf(jwtConfig)
+	require.Equal(t, "https://id.example.com", keys.Issuer)
+	require.Equal(t, time.Hour, keys.TTL)
+	rl.issuerKeys = keys
+
+	// the keys fetched are kept while the issuer and their TTL are the same
+	require.Same(t, keys, rl.keysOf(jwtConfig))
+	jwtConfig.Audience = "streamer"
+	require.Same(t, keys, rl.keysOf(jwtConfig))
+
+	ttl := jwtConfig
+	ttl.KeysTTL = config.Duration(time.Minute)
+	require.NotSame(t, keys, rl.keysOf(ttl))
+	issuer := jwtConfig
+	issuer.Issuer = "https://other.example.com"
+	require.NotSame(t, keys, rl.keysOf(issuer))
+}
@@ -0,0 +1,314 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package schema validates the parameters of the actions with JSON Schemas.
+// It supports the keywords that describe JSON values: type, enum, const,
+// properties, required, additionalProperties, items, minItems, maxItems,
+// minLength, maxLength, pattern, minimum, maximum, exclusiveMinimum and
+// exclusiveMaximum. The annotations, as title and description, are allowed;
+// the schemas using any other keyword, references included, are rejected
+// rather than checked partially.
+package schema
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	Type                 types              `json:"type"`
+	Enum                 []interface{}      `json:"enum"`
+	Const                json.RawMessage    `json:"const"`
+	Properties           map[string]*Schema `json:"properties"`
+	Required             []string           `json:"required"`
+	AdditionalProperties *Schema            `json:"additionalProperties"`
+	Items                *Schema            `json:"items"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	Pattern              string             `json:"pattern"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum"`
+
+	// never is the false schema, that no value matches
+	never   bool
+	pattern *regexp.Regexp
+}
+
+// types is the type keyword, a type or a list of types.
+type types []string
+
+func (t *types) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*t = types{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return errors.New("type must be a string or a list of strings")
+	}
+	*t = list
+	return nil
+}
+
+// annotations are the keywords that do not constrain the values.
+var annotations = []string{
+	"$schema", "$id", "$comment", "title", "description", "default",
+	"examples", "deprecated", "readOnly", "writeOnly",
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch strings.TrimSpace(string(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{never: true}
+		return nil
+	}
+	var keywords map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keywords); err != nil {
+		return errors.New("schema must be an object or a boolean")
+	}
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !supported(name) {
+			return fmt.Errorf("keyword %q is not supported", name)
+		}
+	}
+	type plain Schema
+	return json.Unmarshal(data, (*plain)(s))
+}
+
+// supported tells whether the keyword name is checked, or an annotation.
+func supported(name string) bool {
+	if slices.Contains(annotations, name) {
+		return true
+	}
+	fields := reflect.TypeOf(Schema{})
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).Tag.Get("json") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Load reads a schema from a JSON or YAML file.
+func Load(file string) (*Schema, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing schema %s: %w", file, err)
+	}
+	return s, nil
+}
+
+// Parse reads a schema in JSON or YAML.
+func Parse(data []byte) (*Schema, error) {
+	// YAML is a superset of JSON: the JSON schemas are read as YAML too
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	s := &Schema{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if err := s.compile(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Schema) compile() error {
+	if s.Pattern != "" {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+	for _, t := range s.Type {
+		if !slices.Contains([]string{"object", "array", "string", "number", "integer", "boolean", "null"}, t) {
+			return fmt.Errorf("type %q is not a JSON type", t)
+		}
+	}
+	children := []*Schema{s.AdditionalProperties, s.Items}
+	for _, child := range s.Properties {
+		children = append(children, child)
+	}
+	for _, child := range children {
+		if child != nil {
+			if err := child.compile(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Validate checks v, a value decoded by encoding/json, reporting all the
+// mismatches.
+func (s *Schema) Validate(v interface{}) error {
+	var errs []error
+	s.validate("", v, &errs)
+	return errors.Join(errs...)
+}
+
+func (s *Schema) validate(path string, v interface{}, errs *[]error) {
+	fail := func(format string, args ...interface{}) {
+		name := path
+		if name == "" {
+			name = "parameters"
+		}
+		*errs = append(*errs, fmt.Errorf("%s: "+format, append([]interface{}{name}, args...)...))
+	}
+
+	if s.never {
+		fail("is not allowed")
+		return
+	}
+	if len(s.Type) > 0 && !slices.ContainsFunc(s.Type, func(t string) bool { return hasType(v, t) }) {
+		fail("must be of type %s", strings.Join(s.Type, " or "))
+		return
+	}
+	if s.Enum != nil && !slices.ContainsFunc(s.Enum, func(e interface{}) bool { return reflect.DeepEqual(e, v) }) {
+		fail("must be one of %s", jsonList(s.Enum))
+	}
+	if s.Const != nil {
+		var c interface{}
+		if err := json.Unmarshal(s.Const, &c); err == nil && !reflect.DeepEqual(c, v) {
+			fail("must be %s", s.Const)
+		}
+	}
+
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.Required {
+			if _, ok := value[name]; !ok {
+				*errs = append(*errs, fmt.Errorf("%s: is required", join(path, name)))
+			}
+		}
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := s.Properties[name]; ok {
+				property.validate(join(path, name), value[name], errs)
+			} else if s.AdditionalProperties != nil {
+				s.AdditionalProperties.validate(join(path, name), value[name], errs)
+			}
+		}
+	case []interface{}:
+		if s.MinItems != nil && len(value) < *s.MinItems {
+			fail("must have at least %d items", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(value) > *s.MaxItems {
+			fail("must have at most %d items", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				s.Items.validate(fmt.Sprintf("%s[%d]", path, i), item, errs)
+			}
+		}
+	case string:
+		length := utf8.RuneCountInString(value)
+		if s.MinLength != nil && length < *s.MinLength {
+			fail("must be at least %d characters long", *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			fail("must be at most %d characters long", *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(value) {
+			fail("must match %s", s.Pattern)
+		}
+	case float64:
+		if s.Minimum != nil && value < *s.Minimum {
+			fail("must be at least %v", *s.Minimum)
+		}
+		if s.Maximum != nil && value > *s.Maximum {
+			fail("must be at most %v", *s.Maximum)
+		}
+		if s.ExclusiveMinimum != nil && value <= *s.ExclusiveMinimum {
+			fail("must be more than %v", *s.ExclusiveMinimum)
+		}
+		if s.ExclusiveMaximum != nil && value >= *s.ExclusiveMaximum {
+			fail("must be less than %v", *s.ExclusiveMaximum)
+		}
+	}
+}
+
+func hasType(v interface{}, t string) bool {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return t == "object"
+	case []interface{}:
+		return t == "array"
+	case string:
+		return t == "string"
+	case float64:
+		return t == "number" || (t == "integer" && value == math.Trunc(value))
+	case bool:
+		return t == "boolean"
+	case nil:
+		return t == "null"
+	default:
+		return false
+	}
+}
+
+func join(path string, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func jsonList(values []interface{}) string {
+	data, _ := json.Marshal(values)
+	return string(data)
+}
@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const chatSchema = `
+type: object
+required: [prompt]
+additionalProperties: false
+properties:
+  prompt: {type: string, minLength: 1, maxLength: 20}
+  model: {enum: [small, large]}
+  temperature: {type: number, minimum: 0, maximum: 1}
+  max_tokens: {type: integer, exclusiveMinimum: 0}
+  stop: {type: array, maxItems: 2, items: {type: string, pattern: "^[a-z]+$"}}
+  stream: {const: true}
+  user: {type: [string, "null"]}
+`
+
+func TestValidate(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "chat.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(chatSchema), 0o600))
+	s, err := Load(file)
+	require.NoError(t, err)
+
+	tests := []struct {
+		params   string
+		expected []string
+	}{
+		{`{"prompt": "hi", "model": "small", "temperature": 0.5, "max_tokens": 10, "stop": ["end"], "stream": true, "user": null}`, nil},
+		{`{}`, []string{"prompt: is required"}},
+		{`{"prompt": ""}`, []string{"prompt: must be at least 1 characters long"}},
+		{`{"prompt": 1}`, []string{"prompt: must be of type string"}},
+		{`{"prompt": "hi", "model": "huge"}`, []string{`model: must be one of ["small","large"]`}},
+		{`{"prompt": "hi", "temperature": 2}`, []string{"temperature: must be at most 1"}},
+		{`{"prompt": "hi", "max_tokens": 1.5}`, []string{"max_tokens: must be of type integer"}},
+		{`{"prompt": "hi", "max_tokens": 0}`, []string{"max_tokens: must be more than 0"}},
+		{`{"prompt": "hi", "stop": ["a", "B", "c"]}`, []string{"stop: must have at most 2 items", "stop[1]: must match ^[a-z]+$"}},
+		{`{"prompt": "hi", "stream": false}`, []string{"stream: must be true"}},
+		{`{"prompt": "hi", "user": 1}`, []string{"user: must be of type string or null"}},
+		{`{"prompt": "hi", "debug": true}`, []string{"debug: is not allowed"}},
+		{`[]`, []string{"parameters: must be of type object"}},
+	}
+	for _, tt := range tests {
+		var params interface{}
+		require.NoError(t, json.Unmarshal([]byte(tt.params), &params))
+		err := s.Validate(params)
+		if tt.expected == nil {
+			require.NoError(t, err, tt.params)
+			continue
+		}
+		for _, msg := range tt.expected {
+			require.ErrorContains(t, err, msg, tt.params)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for schema, expected := range map[string]string{
+		`{"type": "text"}`:                           `type "text" is not a JSON type`,
+		`{"properties": {"a": {"pattern": "("}}}`:    "pattern",
+		`{"type": 1}`:                                "type must be a string or a list of strings",
+		`{"properties": {"a": {"type": "list"}}}`:    `type "list" is not a JSON type`,
+		`{"$ref": "#/$defs/a"}`:                      `keyword "$ref" is not supported`,
+		`{"items": {"anyOf": [{"type": "string"}]}}`: `keyword "anyOf" is not supported`,
+		`{"properties": {"a": {"format": "email"}}}`: `keyword "format" is not supported`,
+		`{"properties": {"a": 1}}`:                   "schema must be an object or a boolean",
+	} {
+		_, err := Parse([]byte(schema))
+		require.ErrorContains(t, err, expected, schema)
+	}
+}
@@ -0,0 +1,473 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.6
+// 	protoc        v5.29.3
+// source: streamer.proto
+
+package streamerpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	structpb "google.golang.org/protobuf/types/known/structpb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type InvokeStreamRequest struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	Namespace string                 `protobuf:"bytes,1,opt,name=namespace,proto3" json:"namespace,omitempty"`
+	// package is empty for the default package.
+	Package string `protobuf:"bytes,2,opt,name=package,proto3" json:"package,omitempty"`
+	Action  string `protobuf:"bytes,3,opt,name=action,proto3" json:"action,omitempty"`
+	// parameters are passed to the action, with the ones it needs to stream.
+	Parameters *structpb.Struct `protobuf:"bytes,4,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	// auth is the OpenWhisk API key. When empty, it is read from the
+	// authorization metadata, as a Bearer token.
+	Auth          string `protobuf:"bytes,5,opt,name=auth,proto3" json:"auth,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *InvokeStreamRequest) Reset() {
+	*x = InvokeStreamRequest{}
+	mi := &file_streamer_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *InvokeStreamRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*InvokeStreamRequest) ProtoMessage() {}
+
+func (x *InvokeStreamRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_streamer_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use InvokeStreamRequest.ProtoReflect.Descriptor instead.
+func (*InvokeStreamRequest) Descriptor() ([]byte, []int) {
+	return file_streamer_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *InvokeStreamRequest) GetNamespace() string {
+	if x != nil {
+		return x.Namespace
+	}
+	return ""
+}
+
+func (x *InvokeStreamRequest) GetPackage() string {
+	if x != nil {
+		return x.Package
+	}
+	return ""
+}
+
+func (x *InvokeStreamRequest) GetAction() string {
+	if x != nil {
+		return x.Action
+	}
+	return ""
+}
+
+func (x *InvokeStreamRequest) GetParameters() *structpb.Struct {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *InvokeStreamRequest) GetAuth() string {
+	if x != nil {
+		return x.Auth
+	}
+	return ""
+}
+
+type StreamEvent struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// Types that are valid to be assigned to Event:
+	//
+	//	*StreamEvent_Chunk
+	//	*StreamEvent_Heartbeat
+	//	*StreamEvent_Result
+	Event         isStreamEvent_Event `protobuf_oneof:"event"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *StreamEvent) Reset() {
+	*x = StreamEvent{}
+	mi := &file_streamer_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *StreamEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StreamEvent) ProtoMessage() {}
+
+func (x *StreamEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_streamer_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StreamEvent.ProtoReflect.Descriptor instead.
+func (*StreamEvent) Descriptor() ([]byte, []int) {
+	return file_streamer_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *StreamEvent) GetEvent() isStreamEvent_Event {
+	if x != nil {
+		return x.Event
+	}
+	return nil
+}
+
+func (x *StreamEvent) GetChunk() *Chunk {
+	if x != nil {
+		if x, ok := x.Event.(*StreamEvent_Chunk); ok {
+			return x.Chunk
+		}
+	}
+	return nil
+}
+
+func (x *StreamEvent) GetHeartbeat() *Heartbeat {
+	if x != nil {
+		if x, ok := x.Event.(*StreamEvent_Heartbeat); ok {
+			return x.Heartbeat
+		}
+	}
+	return nil
+}
+
+func (x *StreamEvent) GetResult() *Result {
+	if x != nil {
+		if x, ok := x.Event.(*StreamEvent_Result); ok {
+			return x.Result
+		}
+	}
+	return nil
+}
+
+type isStreamEvent_Event interface {
+	isStreamEvent_Event()
+}
+
+type StreamEvent_Chunk struct {
+	Chunk *Chunk `protobuf:"bytes,1,opt,name=chunk,proto3,oneof"`
+}
+
+type StreamEvent_Heartbeat struct {
+	Heartbeat *Heartbeat `protobuf:"bytes,2,opt,name=heartbeat,proto3,oneof"`
+}
+
+type StreamEvent_Result struct {
+	Result *Result `protobuf:"bytes,3,opt,name=result,proto3,oneof"`
+}
+
+func (*StreamEvent_Chunk) isStreamEvent_Event() {}
+
+func (*StreamEvent_Heartbeat) isStreamEvent_Event() {}
+
+func (*StreamEvent_Result) isStreamEvent_Event() {}
+
+// Chunk is a message written by the action.
+type Chunk struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Seq           int64                  `protobuf:"varint,1,opt,name=seq,proto3" json:"seq,omitempty"`
+	Data          []byte                 `protobuf:"bytes,2,opt,name=data,proto3" json:"data,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Chunk) Reset() {
+	*x = Chunk{}
+	mi := &file_streamer_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Chunk) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Chunk) ProtoMessage() {}
+
+func (x *Chunk) ProtoReflect() protoreflect.Message {
+	mi := &file_streamer_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Chunk.ProtoReflect.Descriptor instead.
+func (*Chunk) Descriptor() ([]byte, []int) {
+	return file_streamer_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Chunk) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *Chunk) GetData() []byte {
+	if x != nil {
+		return x.Data
+	}
+	return nil
+}
+
+// Heartbeat is sent after a while without output from the action.
+type Heartbeat struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Heartbeat) Reset() {
+	*x = Heartbeat{}
+	mi := &file_streamer_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Heartbeat) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Heartbeat) ProtoMessage() {}
+
+func (x *Heartbeat) ProtoReflect() protoreflect.Message {
+	mi := &file_streamer_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Heartbeat.ProtoReflect.Descriptor instead.
+func (*Heartbeat) Descriptor() ([]byte, []int) {
+	return file_streamer_proto_rawDescGZIP(), []int{3}
+}
+
+// Result is the last event of a stream that ended cleanly.
+type Result struct {
+	state        protoimpl.MessageState `protogen:"open.v1"`
+	StreamId     string                 `protobuf:"bytes,1,opt,name=stream_id,json=streamId,proto3" json:"stream_id,omitempty"`
+	ActivationId string                 `protobuf:"bytes,2,opt,name=activation_id,json=activationId,proto3" json:"activation_id,omitempty"`
+	// bytes is the output of the action relayed.
+	Bytes int64 `protobuf:"varint,3,opt,name=bytes,proto3" json:"bytes,omitempty"`
+	// summary is what the action wrote after the end marker.
+	Summary       []byte `protobuf:"bytes,4,opt,name=summary,proto3" json:"summary,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Result) Reset() {
+	*x = Result{}
+	mi := &file_streamer_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Result) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Result) ProtoMessage() {}
+
+func (x *Result) ProtoReflect() protoreflect.Message {
+	mi := &file_streamer_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Result.ProtoReflect.Descriptor instead.
+func (*Result) Descriptor() ([]byte, []int) {
+	return file_streamer_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Result) GetStreamId() string {
+	if x != nil {
+		return x.StreamId
+	}
+	return ""
+}
+
+func (x *Result) GetActivationId() string {
+	if x != nil {
+		return x.ActivationId
+	}
+	return ""
+}
+
+func (x *Result) GetBytes() int64 {
+	if x != nil {
+		return x.Bytes
+	}
+	return 0
+}
+
+func (x *Result) GetSummary() []byte {
+	if x != nil {
+		return x.Summary
+	}
+	return nil
+}
+
+var File_streamer_proto protoreflect.FileDescriptor
+
+const file_streamer_proto_rawDesc = "" +
+	"\n" +
+	"\x0estreamer.proto\x12\x1aopenserverless.streamer.v1\x1a\x1cgoogle/protobuf/struct.proto\"\xb2\x01\n" +
+	"\x13InvokeStreamRequest\x12\x1c\n" +
+	"\tnamespace\x18\x01 \x01(\tR\tnamespace\x12\x18\n" +
+	"\apackage\x18\x02 \x01(\tR\apackage\x12\x16\n" +
+	"\x06action\x18\x03 \x01(\tR\x06action\x127\n" +
+	"\n" +
+	"parameters\x18\x04 \x01(\v2\x17.google.protobuf.StructR\n" +
+	"parameters\x12\x12\n" +
+	"\x04auth\x18\x05 \x01(\tR\x04auth\"\xd6\x01\n" +
+	"\vStreamEvent\x129\n" +
+	"\x05chunk\x18\x01 \x01(\v2!.openserverless.streamer.v1.ChunkH\x00R\x05chunk\x12E\n" +
+	"\theartbeat\x18\x02 \x01(\v2%.openserverless.streamer.v1.HeartbeatH\x00R\theartbeat\x12<\n" +
+	"\x06result\x18\x03 \x01(\v2\".openserverless.streamer.v1.ResultH\x00R\x06resultB\a\n" +
+	"\x05event\"-\n" +
+	"\x05Chunk\x12\x10\n" +
+	"\x03seq\x18\x01 \x01(\x03R\x03seq\x12\x12\n" +
+	"\x04data\x18\x02 \x01(\fR\x04data\"\v\n" +
+	"\tHeartbeat\"z\n" +
+	"\x06Result\x12\x1b\n" +
+	"\tstream_id\x18\x01 \x01(\tR\bstreamId\x12#\n" +
+	"\ractivation_id\x18\x02 \x01(\tR\factivationId\x12\x14\n" +
+	"\x05bytes\x18\x03 \x01(\x03R\x05bytes\x12\x18\n" +
+	"\asummary\x18\x04 \x01(\fR\asummary2v\n" +
+	"\bStreamer\x12j\n" +
+	"\fInvokeStream\x12/.openserverless.streamer.v1.InvokeStreamRequest\x1a'.openserverless.streamer.v1.StreamEvent0\x01B=Z;github.com/apache/openserverless-streaming-proxy/streamerpbb\x06proto3"
+
+var (
+	file_streamer_proto_rawDescOnce sync.Once
+	file_streamer_proto_rawDescData []byte
+)
+
+func file_streamer_proto_rawDescGZIP() []byte {
+	file_streamer_proto_rawDescOnce.Do(func() {
+		file_streamer_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_streamer_proto_rawDesc), len(file_streamer_proto_rawDesc)))
+	})
+	return file_streamer_proto_rawDescData
+}
+
+var file_streamer_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_streamer_proto_goTypes = []any{
+	(*InvokeStreamRequest)(nil), // 0: openserverless.streamer.v1.InvokeStreamRequest
+	(*StreamEvent)(nil),         // 1: openserverless.streamer.v1.StreamEvent
+	(*Chunk)(nil),               // 2: openserverless.streamer.v1.Chunk
+	(*Heartbeat)(nil),           // 3: openserverless.streamer.v1.Heartbeat
+	(*Result)(nil),              // 4: openserverless.streamer.v1.Result
+	(*structpb.Struct)(nil),     // 5: google.protobuf.Struct
+}
+var file_streamer_proto_depIdxs = []int32{
+	5, // 0: openserverless.streamer.v1.InvokeStreamRequest.parameters:type_name -> google.protobuf.Struct
+	2, // 1: openserverless.streamer.v1.StreamEvent.chunk:type_name -> openserverless.streamer.v1.Chunk
+	3, // 2: openserverless.streamer.v1.StreamEvent.heartbeat:type_name -> openserverless.streamer.v1.Heartbeat
+	4, // 3: openserverless.streamer.v1.StreamEvent.result:type_name -> openserverless.streamer.v1.Result
+	0, // 4: openserverless.streamer.v1.Streamer.InvokeStream:input_type -> openserverless.streamer.v1.InvokeStreamRequest
+	1, // 5: openserverless.streamer.v1.Streamer.InvokeStream:output_type -> openserverless.streamer.v1.StreamEvent
+	5, // [5:6] is the sub-list for method output_type
+	4, // [4:5] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
+}
+
+func init() { file_streamer_proto_init() }
+func file_streamer_proto_init() {
+	if File_streamer_proto != nil {
+		return
+	}
+	file_streamer_proto_msgTypes[1].OneofWrappers = []any{
+		(*StreamEvent_Chunk)(nil),
+		(*StreamEvent_Heartbeat)(nil),
+		(*StreamEvent_Result)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_streamer_proto_rawDesc), len(file_streamer_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_streamer_proto_goTypes,
+		DependencyIndexes: file_streamer_proto_depIdxs,
+		MessageInfos:      file_streamer_proto_msgTypes,
+	}.Build()
+	File_streamer_proto = out.File
+	file_streamer_proto_goTypes = nil
+	file_streamer_proto_depIdxs = nil
+}
@@ -0,0 +1,149 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v5.29.3
+// source: streamer.proto
+
+package streamerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Streamer_InvokeStream_FullMethodName = "/openserverless.streamer.v1.Streamer/InvokeStream"
+)
+
+// StreamerClient is the client API for Streamer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Streamer invokes OpenWhisk actions and streams their output back.
+type StreamerClient interface {
+	// InvokeStream invokes an action and streams its output as chunks, ending
+	// with its result. Failures end the call with an error status instead.
+	InvokeStream(ctx context.Context, in *InvokeStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamEvent], error)
+}
+
+type streamerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewStreamerClient(cc grpc.ClientConnInterface) StreamerClient {
+	return &streamerClient{cc}
+}
+
+func (c *streamerClient) InvokeStream(ctx context.Context, in *InvokeStreamRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[StreamEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Streamer_ServiceDesc.Streams[0], Streamer_InvokeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[InvokeStreamRequest, StreamEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Streamer_InvokeStreamClient = grpc.ServerStreamingClient[StreamEvent]
+
+// StreamerServer is the server API for Streamer service.
+// All implementations must embed UnimplementedStreamerServer
+// for forward compatibility.
+//
+// Streamer invokes OpenWhisk actions and streams their output back.
+type StreamerServer interface {
+	// InvokeStream invokes an action and streams its output as chunks, ending
+	// with its result. Failures end the call with an error status instead.
+	InvokeStream(*InvokeStreamRequest, grpc.ServerStreamingServer[StreamEvent]) error
+	mustEmbedUnimplementedStreamerServer()
+}
+
+// UnimplementedStreamerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedStreamerServer struct{}
+
+func (UnimplementedStreamerServer) InvokeStream(*InvokeStreamRequest, grpc.ServerStreamingServer[StreamEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method InvokeStream not implemented")
+}
+func (UnimplementedStreamerServer) mustEmbedUnimplementedStreamerServer() {}
+func (UnimplementedStreamerServer) testEmbeddedByValue()                  {}
+
+// UnsafeStreamerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to StreamerServer will
+// result in compilation errors.
+type UnsafeStreamerServer interface {
+	mustEmbedUnimplementedStreamerServer()
+}
+
+func RegisterStreamerServer(s grpc.ServiceRegistrar, srv StreamerServer) {
+	// If the following call pancis, it indicates UnimplementedStreamerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Streamer_ServiceDesc, srv)
+}
+
+func _Streamer_InvokeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(InvokeStreamRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(StreamerServer).InvokeStream(m, &grpc.GenericServerStream[InvokeStreamRequest, StreamEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Streamer_InvokeStreamServer = grpc.ServerStreamingServer[StreamEvent]
+
+// Streamer_ServiceDesc is the grpc.ServiceDesc for Streamer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Streamer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "openserverless.streamer.v1.Streamer",
+	HandlerType: (*StreamerServer)(nil),
+	Methods:     []grpc.MethodDesc{},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "InvokeStream",
+			Handler:       _Streamer_InvokeStream_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "streamer.proto",
+}
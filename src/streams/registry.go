@@ -0,0 +1,214 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package streams
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+)
+
+// Stream is an active relay from an action socket to an HTTP client.
+type Stream struct {
+	ID        string
+	Kind      string
+	Namespace string
+	Action    string
+	ClientIP  string
+	StartTime time.Time
+	// IngestToken authenticates the action posting its output to the
+	// ingest endpoint instead of writing it to the socket.
+	IngestToken string
+
+	sock         *tcp.SocketsServer
+	cancel       context.CancelFunc
+	done         chan struct{}
+	terminated   atomic.Pointer[string]
+	bytes        atomic.Int64
+	activationID atomic.Value
+}
+
+func NewStream(kind string, namespace string, action string, clientIP string, sock *tcp.SocketsServer, cancel context.CancelFunc) *Stream {
+	return &Stream{
+		ID:          newID(),
+		Kind:        kind,
+		Namespace:   namespace,
+		Action:      action,
+		ClientIP:    clientIP,
+		StartTime:   time.Now(),
+		IngestToken: newID(),
+		sock:        sock,
+		cancel:      cancel,
+		done:        make(chan struct{}),
+	}
+}
+
+// AddBytes records n more bytes relayed to the client.
+func (s *Stream) AddBytes(n int) {
+	s.bytes.Add(int64(n))
+}
+
+// SetActivationID records the OpenWhisk activation of the action.
+func (s *Stream) SetActivationID(id string) {
+	s.activationID.Store(id)
+}
+
+// ActivationID is the OpenWhisk activation of the action, empty until known.
+func (s *Stream) ActivationID() string {
+	id, _ := s.activationID.Load().(string)
+	return id
+}
+
+// BytesRelayed is the amount of output of the action written to the client.
+func (s *Stream) BytesRelayed() int64 {
+	return s.bytes.Load()
+}
+
+// ValidIngestToken tells whether token is the ingest token of the stream.
+func (s *Stream) ValidIngestToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.IngestToken)) == 1
+}
+
+// Ingest relays body as the output of the action, see tcp.SocketsServer.Ingest.
+func (s *Stream) Ingest(body io.Reader, end bool) error {
+	return s.sock.Ingest(body, end)
+}
+
+// Done is closed when the stream is removed from its registry, once over.
+func (s *Stream) Done() <-chan struct{} {
+	return s.done
+}
+
+// Terminate cancels the stream context and closes its socket server, for
+// reason, so that the client is told the stream did not end on its own.
+func (s *Stream) Terminate(reason string) {
+	s.terminated.CompareAndSwap(nil, &reason)
+	s.cancel()
+	s.sock.Close()
+}
+
+// Terminated tells why the stream was terminated, if it was.
+func (s *Stream) Terminated() (string, bool) {
+	if reason := s.terminated.Load(); reason != nil {
+		return *reason, true
+	}
+	return "", false
+}
+
+type Info struct {
+	ID           string    `json:"id"`
+	Kind         string    `json:"kind"`
+	Namespace    string    `json:"namespace"`
+	Action       string    `json:"action"`
+	ActivationID string    `json:"activation_id,omitempty"`
+	ClientIP     string    `json:"client_ip"`
+	TCPHost      string    `json:"tcp_host"`
+	TCPPort      string    `json:"tcp_port"`
+	Socket       string    `json:"socket,omitempty"`
+	StartTime    time.Time `json:"start_time"`
+	Duration     string    `json:"duration"`
+	BytesRelayed int64     `json:"bytes_relayed"`
+	Connected    bool      `json:"connected"`
+}
+
+func (s *Stream) Info() Info {
+	return Info{
+		ID:           s.ID,
+		Kind:         s.Kind,
+		Namespace:    s.Namespace,
+		Action:       s.Action,
+		ActivationID: s.ActivationID(),
+		ClientIP:     s.ClientIP,
+		TCPHost:      s.sock.Host,
+		TCPPort:      s.sock.Port,
+		Socket:       s.sock.SocketPath,
+		StartTime:    s.StartTime,
+		Duration:     time.Since(s.StartTime).Round(time.Millisecond).String(),
+		BytesRelayed: s.BytesRelayed(),
+		Connected:    s.sock.Connected(),
+	}
+}
+
+// Registry keeps track of the active streams.
+type Registry struct {
+	mu      sync.RWMutex
+	streams map[string]*Stream
+}
+
+func NewRegistry() *Registry {
+	return &Registry{streams: make(map[string]*Stream)}
+}
+
+func (r *Registry) Add(s *Stream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streams[s.ID] = s
+}
+
+func (r *Registry) Remove(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.streams[id]; ok {
+		delete(r.streams, id)
+		close(s.done)
+	}
+}
+
+func (r *Registry) Get(id string) (*Stream, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.streams[id]
+	return s, ok
+}
+
+// List returns the info of the active streams, oldest first.
+func (r *Registry) List() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]Info, 0, len(r.streams))
+	for _, s := range r.streams {
+		infos = append(infos, s.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].StartTime.Before(infos[j].StartTime) })
+	return infos
+}
+
+// Terminate stops the stream with the given id for reason, reporting
+// whether it existed.
+func (r *Registry) Terminate(id string, reason string) bool {
+	s, ok := r.Get(id)
+	if !ok {
+		return false
+	}
+	s.Terminate(reason)
+	return true
+}
+
+func newID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,82 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package streams
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/tcp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sock, err := tcp.SetupTcpServer(ctx, "localhost", tcp.Options{})
+	require.NoError(t, err)
+
+	registry := NewRegistry()
+	stream := NewStream("action", "ns", "pkg/action", "10.0.0.1", sock, cancel)
+	registry.Add(stream)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(sock.Host, sock.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	stream.AddBytes(len(<-sock.StreamDataChan))
+
+	infos := registry.List()
+	require.Len(t, infos, 1)
+	require.Equal(t, stream.ID, infos[0].ID)
+	require.Equal(t, "pkg/action", infos[0].Action)
+	require.Equal(t, sock.Port, infos[0].TCPPort)
+	require.Equal(t, int64(5), infos[0].BytesRelayed)
+	require.True(t, infos[0].Connected)
+
+	_, terminated := stream.Terminated()
+	require.False(t, terminated)
+	require.False(t, registry.Terminate("unknown", "test"))
+	require.True(t, registry.Terminate(stream.ID, "test"))
+	reason, terminated := stream.Terminated()
+	require.True(t, terminated)
+	require.Equal(t, "test", reason)
+
+	select {
+	case _, open := <-sock.StreamDataChan:
+		require.False(t, open)
+	case <-time.After(time.Second):
+		require.Fail(t, "Timeout waiting for the stream to terminate")
+	}
+	require.Error(t, ctx.Err())
+	require.False(t, stream.Info().Connected)
+
+	select {
+	case <-stream.Done():
+		require.Fail(t, "Stream done before its removal")
+	default:
+	}
+	registry.Remove(stream.ID)
+	require.Empty(t, registry.List())
+	<-stream.Done()
+	registry.Remove(stream.ID)
+}
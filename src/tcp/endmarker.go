@@ -0,0 +1,102 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tcp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// maxSummary caps the summary an action can send after the end marker.
+const maxSummary = 64 * 1024
+
+func newEndMarker() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return "[[end-of-stream:" + hex.EncodeToString(b) + "]]"
+}
+
+// receive relays what the action wrote, until it writes the end marker:
+// what follows it is kept as the summary of the stream. The marker may be
+// split across writes, so a trailing part of data that could be its start
+// is held back until the next call. data is not retained.
+func (s *SocketsServer) receive(data []byte) bool {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+
+	if s.ended {
+		s.summary = appendCapped(s.summary, data)
+		return true
+	}
+
+	buf := append(bytes.Clone(s.pending), data...)
+	s.pending = nil
+	marker := []byte(s.EndMarker)
+
+	if len(marker) > 0 {
+		if i := bytes.Index(buf, marker); i >= 0 {
+			s.ended = true
+			s.summary = appendCapped(nil, buf[i+len(marker):])
+			return i == 0 || s.send(buf[:i])
+		}
+		keep := partialMarker(buf, marker)
+		s.pending = buf[len(buf)-keep:]
+		buf = buf[:len(buf)-keep]
+	}
+
+	return len(buf) == 0 || s.send(buf)
+}
+
+// flushPending relays what was held back as a possible start of the end
+// marker, once the action is done writing.
+func (s *SocketsServer) flushPending() {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	if len(s.pending) > 0 {
+		s.send(s.pending)
+		s.pending = nil
+	}
+}
+
+// Summary is what the action wrote after the end marker, and whether it
+// wrote the marker at all.
+func (s *SocketsServer) Summary() ([]byte, bool) {
+	s.scanMu.Lock()
+	defer s.scanMu.Unlock()
+	return bytes.Clone(s.summary), s.ended
+}
+
+// partialMarker is the length of the longest suffix of buf that is a prefix
+// of marker.
+func partialMarker(buf []byte, marker []byte) int {
+	for n := min(len(buf), len(marker)-1); n > 0; n-- {
+		if bytes.HasPrefix(marker, buf[len(buf)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
+
+func appendCapped(summary []byte, data []byte) []byte {
+	room := maxSummary - len(summary)
+	if room <= 0 {
+		return summary
+	}
+	return append(summary, data[:min(len(data), room)]...)
+}
@@ -0,0 +1,103 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tcp
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReceiveEndMarker(t *testing.T) {
+	tests := []struct {
+		name            string
+		writes          []string
+		expectedOutput  string
+		expectedSummary string
+		expectedEnded   bool
+	}{
+		{
+			name:           "no marker",
+			writes:         []string{"hello ", "world"},
+			expectedOutput: "hello world",
+		},
+		{
+			name:            "marker in a single write",
+			writes:          []string{"hello<END>{\"tokens\": 3}"},
+			expectedOutput:  "hello",
+			expectedSummary: `{"tokens": 3}`,
+			expectedEnded:   true,
+		},
+		{
+			name:            "marker split across writes",
+			writes:          []string{"hello<E", "N", "D>sum", "mary"},
+			expectedOutput:  "hello",
+			expectedSummary: "summary",
+			expectedEnded:   true,
+		},
+		{
+			name:           "marker without summary",
+			writes:         []string{"hello", "<END>"},
+			expectedOutput: "hello",
+			expectedEnded:  true,
+		},
+		{
+			name:           "partial marker relayed",
+			writes:         []string{"a<E", "x", "b<EN"},
+			expectedOutput: "a<Exb<EN",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := &SocketsServer{
+				ctx:            ctx,
+				EndMarker:      "<END>",
+				StreamDataChan: make(chan []byte),
+			}
+
+			output := make(chan string)
+			go func() {
+				var sb strings.Builder
+				for data := range s.StreamDataChan {
+					sb.Write(data)
+				}
+				output <- sb.String()
+			}()
+
+			buf := make([]byte, 64)
+			for _, write := range tt.writes {
+				// the buffer is reused, as when reading from the socket
+				n := copy(buf, write)
+				require.True(t, s.receive(buf[:n]))
+			}
+			s.flushPending()
+			s.finish()
+
+			require.Equal(t, tt.expectedOutput, <-output)
+			summary, ended := s.Summary()
+			require.Equal(t, tt.expectedSummary, string(summary))
+			require.Equal(t, tt.expectedEnded, ended)
+		})
+	}
+}
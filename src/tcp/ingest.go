@@ -0,0 +1,66 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tcp
+
+import (
+	"errors"
+	"io"
+)
+
+// ErrStreamFinished is returned when data arrives for a stream that is over.
+var ErrStreamFinished = errors.New("stream already finished")
+
+// Ingest relays body to StreamDataChan as if the action had written it to
+// the socket, for the actions that can only reach the streamer over HTTP.
+// The bodies of concurrent calls are relayed one after the other. With end
+// the stream is over once body is relayed.
+func (s *SocketsServer) Ingest(body io.Reader, end bool) error {
+	s.ingestMu.Lock()
+	defer s.ingestMu.Unlock()
+
+	s.sendMu.RLock()
+	finished := s.finished
+	s.sendMu.RUnlock()
+	if finished {
+		return ErrStreamFinished
+	}
+
+	s.connected.Store(true)
+	defer s.connected.Store(false)
+
+	buf := make([]byte, 2048)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 && !s.receive(buf[:n]) {
+			return ErrStreamFinished
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if end {
+		s.flushPending()
+		s.finish()
+		s.closeListener()
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ErrNoFreePort is returned when all the ports of the range are in use.
+var ErrNoFreePort = errors.New("no free port for the action socket")
+
+// PortRange restricts the ports of the sockets, the zero value allows any
+// free port.
+type PortRange struct {
+	Min int
+	Max int
+}
+
+func (r PortRange) Size() int {
+	if r.Min == 0 {
+		return 0
+	}
+	return r.Max - r.Min + 1
+}
+
+func (r PortRange) String() string {
+	if r.Min == 0 {
+		return "any"
+	}
+	return fmt.Sprintf("%d-%d", r.Min, r.Max)
+}
+
+// Metrics counts the sockets opened for the actions since the start.
+type Metrics struct {
+	Listening      int64 `json:"listening"`
+	Opened         int64 `json:"opened_total"`
+	PortsExhausted int64 `json:"ports_exhausted_total"`
+}
+
+var (
+	// nextPort spreads the sockets over the range, so that a port just
+	// released is not the first one tried again
+	nextPort atomic.Uint64
+
+	listening      atomic.Int64
+	opened         atomic.Int64
+	portsExhausted atomic.Int64
+)
+
+func GetMetrics() Metrics {
+	return Metrics{
+		Listening:      listening.Load(),
+		Opened:         opened.Load(),
+		PortsExhausted: portsExhausted.Load(),
+	}
+}
+
+// listen opens a socket on host, on a free port of ports.
+func listen(host string, ports PortRange, keepAlive time.Duration) (net.Listener, error) {
+	lc := net.ListenConfig{KeepAlive: keepAlive}
+	listener, err := listenInRange(lc, host, ports)
+	if errors.Is(err, ErrNoFreePort) {
+		portsExhausted.Add(1)
+	}
+	if err != nil {
+		return nil, err
+	}
+	listening.Add(1)
+	opened.Add(1)
+	return listener, nil
+}
+
+func listenInRange(lc net.ListenConfig, host string, ports PortRange) (net.Listener, error) {
+	size := ports.Size()
+	if size == 0 {
+		return lc.Listen(context.Background(), "tcp", net.JoinHostPort(host, "0"))
+	}
+
+	start := nextPort.Add(1) - 1
+	for i := 0; i < size; i++ {
+		port := ports.Min + int((start+uint64(i))%uint64(size))
+		listener, err := lc.Listen(context.Background(), "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err == nil {
+			return listener, nil
+		}
+		if !errors.Is(err, syscall.EADDRINUSE) {
+			return nil, err
+		}
+	}
+	return nil, fmt.Errorf("%w in the range %s", ErrNoFreePort, ports)
+}
@@ -19,61 +19,197 @@ package tcp
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/apache/openserverless-streaming-proxy/certs"
 )
 
+const handshakeTimeout = 10 * time.Second
+
+// Options configures the listeners the actions stream to.
+type Options struct {
+	// TLS, when set, makes the actions connect with TLS, using the current
+	// certificate of the reloader.
+	TLS *certs.Reloader
+
+	// AdvertiseHost is the host given to the actions, instead of the address
+	// the socket is bound to, for when that is not reachable by them.
+	AdvertiseHost string
+
+	// Ports restricts the ports of the sockets.
+	Ports PortRange
+
+	// KeepAlive is the period of the TCP keepalive probes on the action
+	// connections, to detect dead peers. 0 uses the default of 15s, a
+	// negative value disables them.
+	KeepAlive time.Duration
+
+	// SocketDir, when set, makes the streamer listen on a Unix socket in
+	// this directory instead of a TCP port, for the actions on the same host.
+	SocketDir string
+
+	// SocketMode is the permissions of the Unix sockets, 0660 when 0.
+	SocketMode os.FileMode
+
+	// SocketGID, when not 0, is the group of the Unix sockets, for the
+	// actions running as another user.
+	SocketGID int
+}
+
 type SocketsServer struct {
 	ctx            context.Context
 	listener       net.Listener
 	Host           string
 	Port           string
 	StreamDataChan chan []byte
+
+	// SocketPath is the Unix socket listened on, empty for TCP.
+	SocketPath string
+
+	// EndMarker, written by the action, ends its output. What follows it is
+	// a summary of the stream.
+	EndMarker string
+
+	// TLSFingerprint is the SHA-256 of the certificate presented to the
+	// action, empty without TLS.
+	TLSFingerprint string
+
+	connMu    sync.Mutex
+	conn      net.Conn
+	connected atomic.Bool
+	err       atomic.Pointer[error]
+	closeOnce sync.Once
+
+	// sendMu guards StreamDataChan, which has more than one producer when
+	// the action also posts to the ingest endpoint
+	sendMu   sync.RWMutex
+	finished bool
+	ingestMu sync.Mutex
+
+	scanMu  sync.Mutex
+	pending []byte
+	ended   bool
+	summary []byte
 }
 
-func SetupTcpServer(ctx context.Context, streamingProxyAddr string) (*SocketsServer, error) {
-	socketServer, err := startTCPServer(ctx, streamingProxyAddr)
+func SetupTcpServer(ctx context.Context, streamingProxyAddr string, opts Options) (*SocketsServer, error) {
+	socketServer, err := startTCPServer(ctx, streamingProxyAddr, opts)
 	if err != nil {
 		return nil, err
 	}
+	go socketServer.acceptConnections()
 	go socketServer.WaitToCleanUp()
 
-	tcpServerHost, tcpServerPort, err := net.SplitHostPort(socketServer.listener.Addr().String())
-	if err != nil {
-		return nil, err
-	}
-
-	socketServer.Host = tcpServerHost
-	socketServer.Port = tcpServerPort
-
 	return socketServer, nil
 }
 
-func startTCPServer(ctx context.Context, streamingProxyAddr string) (*SocketsServer, error) {
-	listener, err := net.Listen("tcp", streamingProxyAddr+":0")
+func startTCPServer(ctx context.Context, streamingProxyAddr string, opts Options) (*SocketsServer, error) {
+	if opts.SocketDir != "" {
+		listener, path, err := listenUnix(opts)
+		if err != nil {
+			return nil, fmt.Errorf("Error starting Unix socket server: %w", err)
+		}
+		listening.Add(1)
+		opened.Add(1)
+		s := &SocketsServer{
+			ctx:            ctx,
+			listener:       listener,
+			SocketPath:     path,
+			EndMarker:      newEndMarker(),
+			StreamDataChan: make(chan []byte),
+		}
+		s.setupTLS(opts)
+		return s, nil
+	}
+
+	listener, err := listen(streamingProxyAddr, opts.Ports, opts.KeepAlive)
 	if err != nil {
-		return nil, errors.New("Error starting TCP server")
+		return nil, fmt.Errorf("Error starting TCP server: %w", err)
+	}
+
+	// the address is known before any goroutine reads it
+	tcpServerHost, tcpServerPort, err := net.SplitHostPort(listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		listening.Add(-1)
+		return nil, err
 	}
 
 	s := &SocketsServer{
 		ctx:            ctx,
 		listener:       listener,
+		Host:           tcpServerHost,
+		Port:           tcpServerPort,
+		EndMarker:      newEndMarker(),
 		StreamDataChan: make(chan []byte),
 	}
-
-	go s.acceptConnections()
+	if opts.AdvertiseHost != "" {
+		// IPv6 addresses are given without brackets, like the bound ones
+		s.Host = strings.TrimSuffix(strings.TrimPrefix(opts.AdvertiseHost, "["), "]")
+	}
+	s.setupTLS(opts)
 
 	return s, nil
 }
 
+// Probe tells whether a socket can be opened for an action on host, as
+// SetupTcpServer would, closing it right away. Probes are not counted in the
+// metrics.
+func Probe(host string, opts Options) error {
+	if opts.SocketDir != "" {
+		listener, path, err := listenUnix(opts)
+		if err != nil {
+			return err
+		}
+		listener.Close()
+		removeSocket(path)
+		return nil
+	}
+	listener, err := listenInRange(net.ListenConfig{}, host, opts.Ports)
+	if err != nil {
+		return err
+	}
+	return listener.Close()
+}
+
+func (s *SocketsServer) setupTLS(opts Options) {
+	if opts.TLS != nil {
+		// the certificate is fixed for the stream, so that the fingerprint
+		// given to the action matches even if it is rotated meanwhile
+		cert := opts.TLS.Certificate()
+		fingerprint := sha256.Sum256(cert.Certificate[0])
+		s.TLSFingerprint = hex.EncodeToString(fingerprint[:])
+		s.listener = tls.NewListener(s.listener, &tls.Config{
+			MinVersion:   tls.VersionTLS12,
+			Certificates: []tls.Certificate{*cert},
+		})
+	}
+}
+
+// name identifies the socket in the logs.
+func (s *SocketsServer) name() string {
+	if s.SocketPath != "" {
+		return s.SocketPath
+	}
+	return s.Port
+}
+
 func (s *SocketsServer) acceptConnections() {
-	_, port, _ := net.SplitHostPort(s.listener.Addr().String())
-	log.Println("TCP server listening on:", port)
-	defer close(s.StreamDataChan)
+	log.Println("TCP server listening on:", s.name())
+	defer s.finish()
+	defer s.flushPending()
 
 	for {
 		conn, err := s.listener.Accept()
@@ -82,6 +218,9 @@ func (s *SocketsServer) acceptConnections() {
 			case <-s.ctx.Done():
 				return
 			default:
+				if errors.Is(err, net.ErrClosed) {
+					return
+				}
 				log.Println("accept error, retrying...", err.Error())
 			}
 		} else {
@@ -92,8 +231,25 @@ func (s *SocketsServer) acceptConnections() {
 }
 
 func (s *SocketsServer) handleConnection(conn net.Conn) {
+	s.connMu.Lock()
+	s.conn = conn
+	s.connMu.Unlock()
+	s.connected.Store(true)
+	defer s.connected.Store(false)
 	defer conn.Close()
-	log.Println(fmt.Sprintf("%s: accepted connection", s.Port))
+	log.Println(fmt.Sprintf("%s: accepted connection", s.name()))
+
+	// a failed handshake can not be retried, so it gets a longer deadline
+	// than the reads below
+	if tlsConn, ok := conn.(*tls.Conn); ok {
+		conn.SetDeadline(time.Now().Add(handshakeTimeout))
+		if err := tlsConn.HandshakeContext(s.ctx); err != nil {
+			log.Println("TLS handshake with the action failed:", err)
+			s.fail(fmt.Errorf("TLS handshake with the action failed: %w", err))
+			return
+		}
+	}
+
 	buf := make([]byte, 2048)
 
 ReadLoop:
@@ -109,10 +265,12 @@ ReadLoop:
 				n, err := conn.Read(buf)
 
 				if err != nil {
-					if opErr, ok := err.(*net.OpError); ok && opErr.Timeout() {
+					var netErr net.Error
+					if errors.As(err, &netErr) && netErr.Timeout() {
 						continue ReadLoop
 					} else if err != io.EOF {
 						log.Println("Error reading from TCP connection", err)
+						s.fail(err)
 						return
 					} else {
 						log.Println("Client closed connection")
@@ -124,14 +282,104 @@ ReadLoop:
 					continue ReadLoop
 				}
 
-				s.StreamDataChan <- buf[:n]
+				if !s.receive(buf[:n]) {
+					return
+				}
 			}
 		}
 	}
 }
 
+// send relays data to the reader of StreamDataChan, unless the stream is
+// over.
+func (s *SocketsServer) send(data []byte) bool {
+	s.sendMu.RLock()
+	defer s.sendMu.RUnlock()
+	if s.finished {
+		return false
+	}
+	select {
+	case s.StreamDataChan <- data:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
+
+// finish closes StreamDataChan, once all the pending sends are done.
+func (s *SocketsServer) finish() {
+	s.sendMu.Lock()
+	defer s.sendMu.Unlock()
+	if !s.finished {
+		s.finished = true
+		close(s.StreamDataChan)
+	}
+}
+
+// fail records why the action connection broke, unless the streamer itself
+// closed it.
+func (s *SocketsServer) fail(err error) {
+	if s.ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+		return
+	}
+	s.err.CompareAndSwap(nil, &err)
+}
+
+// Err tells why the action connection broke, nil if it ended cleanly.
+func (s *SocketsServer) Err() error {
+	if err := s.err.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+// Params are the parameters the action needs to connect to the socket.
+func (s *SocketsServer) Params() map[string]string {
+	params := map[string]string{
+		"STREAM_HOST": s.Host,
+		"STREAM_PORT": s.Port,
+	}
+	if s.SocketPath != "" {
+		params = map[string]string{"STREAM_SOCKET": s.SocketPath}
+	}
+	if s.EndMarker != "" {
+		params["STREAM_END_MARKER"] = s.EndMarker
+	}
+	if s.TLSFingerprint != "" {
+		params["STREAM_TLS"] = "1"
+		params["STREAM_TLS_FINGERPRINT"] = s.TLSFingerprint
+	}
+	return params
+}
+
+// Connected reports whether the action is currently connected to the socket.
+func (s *SocketsServer) Connected() bool {
+	return s.connected.Load()
+}
+
+// Close stops listening and drops the action connection, if any, without
+// waiting for the context to be done.
+func (s *SocketsServer) Close() {
+	s.closeListener()
+	s.connMu.Lock()
+	defer s.connMu.Unlock()
+	if s.conn != nil {
+		_ = s.conn.Close()
+	}
+}
+
 func (s *SocketsServer) WaitToCleanUp() {
 	<-s.ctx.Done()
-	_ = s.listener.Close()
-	log.Println(fmt.Sprintf("%s: stopped listening", s.Port))
+	s.closeListener()
+	log.Println(fmt.Sprintf("%s: stopped listening", s.name()))
+}
+
+func (s *SocketsServer) closeListener() {
+	s.closeOnce.Do(func() {
+		_ = s.listener.Close()
+		listening.Add(-1)
+		if s.SocketPath != "" {
+			removeSocket(s.SocketPath)
+		}
+	})
 }
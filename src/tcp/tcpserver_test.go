@@ -19,11 +19,26 @@ package tcp
 
 import (
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"math/big"
 	"net"
+	"os"
+	"path/filepath"
+	"strconv"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/apache/openserverless-streaming-proxy/certs"
 	"github.com/stretchr/testify/require"
 )
 
@@ -108,7 +123,7 @@ func TestSetupTcpServer(t *testing.T) {
 	defer cancel()
 
 	streamingProxyAddr := "localhost"
-	server, err := SetupTcpServer(ctx, streamingProxyAddr)
+	server, err := SetupTcpServer(ctx, streamingProxyAddr, Options{})
 	require.NoError(t, err)
 	defer server.listener.Close()
 
@@ -124,6 +139,186 @@ func TestSetupTcpServerInvalidAddress(t *testing.T) {
 	defer cancel()
 
 	streamingProxyAddr := "invalid address"
-	_, err := SetupTcpServer(ctx, streamingProxyAddr)
+	_, err := SetupTcpServer(ctx, streamingProxyAddr, Options{})
 	require.Error(t, err)
 }
+
+// newTestCertReloader writes a self-signed certificate and loads it.
+func newTestCertReloader(t *testing.T) *certs.Reloader {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "streamer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	reloader, err := certs.NewReloader(certFile, keyFile, "")
+	require.NoError(t, err)
+	return reloader
+}
+
+func TestSetupTcpServerTLS(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := SetupTcpServer(ctx, "localhost", Options{TLS: newTestCertReloader(t)})
+	require.NoError(t, err)
+
+	params := server.Params()
+	require.Equal(t, "1", params["STREAM_TLS"])
+	require.Len(t, params["STREAM_TLS_FINGERPRINT"], 64)
+
+	// the action pins the certificate with the fingerprint
+	conn, err := tls.Dial("tcp", net.JoinHostPort(params["STREAM_HOST"], params["STREAM_PORT"]), &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			sum := sha256.Sum256(rawCerts[0])
+			if hex.EncodeToString(sum[:]) != params["STREAM_TLS_FINGERPRINT"] {
+				return errors.New("fingerprint mismatch")
+			}
+			return nil
+		},
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte("secret data"))
+	require.NoError(t, err)
+
+	select {
+	case data := <-server.StreamDataChan:
+		require.Equal(t, "secret data", string(data))
+	case <-time.After(time.Second):
+		require.Fail(t, "Timeout waiting for data")
+	}
+}
+
+func TestSetupTcpServerPlainParams(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := SetupTcpServer(ctx, "localhost", Options{})
+	require.NoError(t, err)
+
+	params := server.Params()
+	require.Equal(t, map[string]string{
+		"STREAM_HOST":       server.Host,
+		"STREAM_PORT":       server.Port,
+		"STREAM_END_MARKER": server.EndMarker,
+	}, params)
+}
+
+func TestSetupTcpServerPortRange(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// find a port that is free right now
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+
+	before := GetMetrics()
+	opts := Options{Ports: PortRange{Min: port, Max: port}}
+	server, err := SetupTcpServer(ctx, "127.0.0.1", opts)
+	require.NoError(t, err)
+	require.Equal(t, strconv.Itoa(port), server.Port)
+
+	_, err = SetupTcpServer(ctx, "127.0.0.1", opts)
+	require.ErrorIs(t, err, ErrNoFreePort)
+	require.ErrorIs(t, Probe("127.0.0.1", opts), ErrNoFreePort)
+
+	metrics := GetMetrics()
+	require.Equal(t, before.Opened+1, metrics.Opened)
+	require.Equal(t, before.Listening+1, metrics.Listening)
+	require.Equal(t, before.PortsExhausted+1, metrics.PortsExhausted)
+
+	server.Close()
+	server.Close()
+	require.Equal(t, before.Listening, GetMetrics().Listening)
+	require.NoError(t, Probe("127.0.0.1", opts))
+	require.Equal(t, metrics.Opened, GetMetrics().Opened)
+}
+
+func TestSetupTcpServerAdvertiseHost(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := SetupTcpServer(ctx, "127.0.0.1", Options{AdvertiseHost: "[2001:db8::1]"})
+	require.NoError(t, err)
+	require.Equal(t, "2001:db8::1", server.Params()["STREAM_HOST"])
+}
+
+func TestSetupTcpServerIPv6(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, err := SetupTcpServer(ctx, "::1", Options{})
+	if err != nil {
+		t.Skip("IPv6 loopback not available:", err)
+	}
+	require.Equal(t, "::1", server.Host)
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(server.Host, server.Port))
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(<-server.StreamDataChan))
+}
+
+func TestSetupTcpServerUnixSocket(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	dir := t.TempDir()
+	server, err := SetupTcpServer(ctx, "localhost", Options{SocketDir: dir})
+	require.NoError(t, err)
+	require.Equal(t, dir, filepath.Dir(server.SocketPath))
+	require.NoError(t, Probe("localhost", Options{SocketDir: dir}))
+	require.Error(t, Probe("localhost", Options{SocketDir: filepath.Join(dir, "missing")}))
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	info, err := os.Stat(server.SocketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o660), info.Mode().Perm())
+	require.Equal(t, map[string]string{
+		"STREAM_SOCKET":     server.SocketPath,
+		"STREAM_END_MARKER": server.EndMarker,
+	}, server.Params())
+
+	conn, err := net.Dial("unix", server.SocketPath)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(<-server.StreamDataChan))
+
+	cancel()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(server.SocketPath)
+		return errors.Is(err, os.ErrNotExist)
+	}, time.Second, 10*time.Millisecond)
+
+	// the mode and group are configurable, to the groups of the streamer
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	server, err = SetupTcpServer(ctx, "localhost", Options{SocketDir: dir, SocketMode: 0o600, SocketGID: os.Getgid()})
+	require.NoError(t, err)
+	info, err = os.Stat(server.SocketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+}
@@ -0,0 +1,69 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package tcp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// defaultSocketMode lets the group of the socket, and not the other local
+// users, write to it.
+const defaultSocketMode os.FileMode = 0o660
+
+// listenUnix opens a Unix socket with a new name in opts.SocketDir, for the
+// actions running on the same host, with the mode and group of opts.
+func listenUnix(opts Options) (net.Listener, string, error) {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	path := filepath.Join(opts.SocketDir, "stream-"+hex.EncodeToString(b)+".sock")
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, "", err
+	}
+	mode := opts.SocketMode
+	if mode == 0 {
+		mode = defaultSocketMode
+	}
+	// the actions may run as another user, of the group of the socket
+	if opts.SocketGID != 0 {
+		err = os.Chown(path, -1, opts.SocketGID)
+	}
+	if err == nil {
+		err = os.Chmod(path, mode)
+	}
+	if err != nil {
+		listener.Close()
+		return nil, "", err
+	}
+	return listener, path, nil
+}
+
+// removeSocket deletes the socket file, in case closing the listener left it.
+func removeSocket(path string) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Println("Error removing socket file:", err)
+	}
+}
@@ -0,0 +1,53 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Callers maps the API keys given to the callers of the streamer to the
+// namespaces they may invoke the actions of, "*" for all of them.
+type Callers map[string][]string
+
+// LoadCallers reads the callers from a YAML file.
+func LoadCallers(file string) (Callers, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	callers := Callers{}
+	if err := yaml.Unmarshal(data, &callers); err != nil {
+		return nil, fmt.Errorf("parsing callers file %s: %w", file, err)
+	}
+	return callers, nil
+}
+
+// Allowed tells whether key is a caller key, and whether it may invoke the
+// actions of namespace.
+func (c Callers) Allowed(key string, namespace string) (known bool, allowed bool) {
+	namespaces, known := c[key]
+	if !known || key == "" {
+		return false, false
+	}
+	return true, slices.Contains(namespaces, "*") || slices.Contains(namespaces, namespace)
+}
@@ -0,0 +1,95 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+// Package vault keeps the OpenWhisk keys of the namespaces on the server, for
+// the callers that authenticate with credentials of their own.
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNoKey is returned for the namespaces without a key in the vault.
+var ErrNoKey = errors.New("no key for the namespace")
+
+// Vault holds the OpenWhisk key of every namespace, read from a YAML file
+// mapping the namespaces to their keys, or from a directory with a file per
+// namespace, as a mounted Kubernetes secret. The files of the directory are
+// read on every lookup, so that rotated secrets are used without a restart.
+type Vault struct {
+	keys map[string]string
+	dir  string
+}
+
+// Open reads the keys of file, if set, and looks up the others in dir, if
+// set.
+func Open(file string, dir string) (*Vault, error) {
+	v := &Vault{keys: make(map[string]string), dir: dir}
+	if file != "" {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &v.keys); err != nil {
+			return nil, fmt.Errorf("parsing vault file %s: %w", file, err)
+		}
+	}
+	if dir != "" {
+		info, err := os.Stat(dir)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("vault dir %s is not a directory", dir)
+		}
+	}
+	return v, nil
+}
+
+// Key returns the OpenWhisk key of namespace.
+func (v *Vault) Key(namespace string) (string, error) {
+	if key, ok := v.keys[namespace]; ok && key != "" {
+		return key, nil
+	}
+	if v.dir == "" || !validName(namespace) {
+		return "", ErrNoKey
+	}
+	data, err := os.ReadFile(filepath.Join(v.dir, namespace))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNoKey
+	}
+	if err != nil {
+		return "", err
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", ErrNoKey
+	}
+	return key, nil
+}
+
+// validName rejects the namespaces that are not a file of the directory,
+// hidden ones included: Kubernetes keeps the secret versions there.
+func validName(namespace string) bool {
+	return namespace != "" && !strings.HasPrefix(namespace, ".") && !strings.ContainsAny(namespace, `/\`)
+}
@@ -0,0 +1,90 @@
+// Licensed to the Apache Software Foundation (ASF) under one
+// or more contributor license agreements.  See the NOTICE file
+// distributed with this work for additional information
+// regarding copyright ownership.  The ASF licenses this file
+// to you under the Apache License, Version 2.0 (the
+// "License"); you may not use this file except in compliance
+// with the License.  You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing,
+// software distributed under the License is distributed on an
+// "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY
+// KIND, either express or implied.  See the License for the
+// specific language governing permissions and limitations
+// under the License.
+
+package vault
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVault(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "keys.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("prod: uuid:prod\n"), 0o600))
+
+	// a mounted secret, with its hidden versions
+	secrets := filepath.Join(dir, "secrets")
+	require.NoError(t, os.MkdirAll(filepath.Join(secrets, "..data"), 0o700))
+	require.NoError(t, os.WriteFile(filepath.Join(secrets, "dev"), []byte("uuid:dev\n"), 0o600))
+
+	v, err := Open(file, secrets)
+	require.NoError(t, err)
+
+	key, err := v.Key("prod")
+	require.NoError(t, err)
+	require.Equal(t, "uuid:prod", key)
+
+	key, err = v.Key("dev")
+	require.NoError(t, err)
+	require.Equal(t, "uuid:dev", key)
+
+	// rotated secrets are read again
+	require.NoError(t, os.WriteFile(filepath.Join(secrets, "dev"), []byte("uuid:rotated"), 0o600))
+	key, err = v.Key("dev")
+	require.NoError(t, err)
+	require.Equal(t, "uuid:rotated", key)
+
+	for _, namespace := range []string{"staging", "..data", "..", "../secrets/dev", ""} {
+		_, err = v.Key(namespace)
+		require.ErrorIs(t, err, ErrNoKey, namespace)
+	}
+
+	_, err = Open(filepath.Join(dir, "missing.yaml"), "")
+	require.Error(t, err)
+	_, err = Open("", file)
+	require.ErrorContains(t, err, "is not a directory")
+}
+
+func TestCallers(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "callers.yaml")
+	require.NoError(t, os.WriteFile(file, []byte("web-app: [prod, dev]\nops: ['*']\n"), 0o600))
+
+	callers, err := LoadCallers(file)
+	require.NoError(t, err)
+
+	tests := []struct {
+		key       string
+		namespace string
+		known     bool
+		allowed   bool
+	}{
+		{"web-app", "prod", true, true},
+		{"web-app", "staging", true, false},
+		{"ops", "staging", true, true},
+		{"unknown", "prod", false, false},
+		{"", "prod", false, false},
+	}
+	for _, tt := range tests {
+		known, allowed := callers.Allowed(tt.key, tt.namespace)
+		require.Equal(t, tt.known, known, tt.key)
+		require.Equal(t, tt.allowed, allowed, tt.key)
+	}
+}
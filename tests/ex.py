@@ -15,7 +15,9 @@
 # specific language governing permissions and limitations
 # under the License.
 
+import hashlib
 import socket
+import ssl
 import time
 
 example_data = [
@@ -31,14 +33,13 @@ def main(args):
 
     streamer = (args.get("STREAM_HOST"), args.get("STREAM_PORT"))
 
-    if not streamer[0] or not streamer[1]:
+    if not args.get("STREAM_SOCKET") and (not streamer[0] or not streamer[1]):
         return {"body": "please provide a STREAM_HOST and STREAM_PORT"}
 
     print(f"streamer: {streamer}")
 
     # # invoke a call to a streaming api server like OpenAI
-    with socket.socket(socket.AF_INET, socket.SOCK_STREAM) as s:
-        s.connect((streamer[0], int(streamer[1])))
+    with connect(streamer, args) as s:
 
         for ex in example_data:
             time.sleep(1)
@@ -47,7 +48,37 @@ def main(args):
                 print(f"sending: {ex}")
                 s.sendall(ex.encode())
 
+        # the summary after the end marker is added to the end record
+        if args.get("STREAM_END_MARKER"):
+            s.sendall((args.get("STREAM_END_MARKER") + '{"chunks": %d}' % len(example_data)).encode())
+
         print("done sending")
         s.close()
 
     return {"body": "done"}
+
+def connect(streamer, args):
+    if args.get("STREAM_SOCKET"):
+        s = socket.socket(socket.AF_UNIX, socket.SOCK_STREAM)
+        s.connect(args.get("STREAM_SOCKET"))
+    else:
+        s = socket.create_connection((streamer[0], int(streamer[1])))
+    if args.get("STREAM_TLS") != "1":
+        return s
+
+    # the streamer certificate is pinned with its fingerprint
+    ctx = ssl.SSLContext(ssl.PROTOCOL_TLS_CLIENT)
+    ctx.check_hostname = False
+    ctx.verify_mode = ssl.CERT_NONE
+    s = ctx.wrap_socket(s)
+    fingerprint = hashlib.sha256(s.getpeercert(binary_form=True)).hexdigest()
+    if fingerprint != args.get("STREAM_TLS_FINGERPRINT"):
+        s.close()
+        raise ssl.SSLError("streamer certificate does not match STREAM_TLS_FINGERPRINT")
+    return s
+
+# run by the dev mode of the streamer, with the parameters on stdin
+if __name__ == "__main__":
+    import json
+    import sys
+    print(json.dumps(main(json.load(sys.stdin))))